@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Grafana simple-JSON datasource target suffixes.
+const (
+	grafanaStatusSuffix  = " status"
+	grafanaLatencySuffix = " latency"
+)
+
+// apiServer is the optional HTTP server that runs alongside the monitoring loop.
+type apiServer struct {
+	server    *http.Server
+	history   *historyStore
+	snoozes   *snoozeStore
+	summaries *summaryStore
+	statuses  *statusStore
+	state     *stateStore
+	db        *resultsDB // nil without -db
+	token     string     // Bearer token required to snooze; empty allows loopback clients only
+	started   time.Time
+}
+
+func newAPIServer(addr, token string, history *historyStore, snoozes *snoozeStore, summaries *summaryStore, statuses *statusStore, state *stateStore, db *resultsDB) *apiServer {
+	a := &apiServer{history: history, snoozes: snoozes, summaries: summaries, statuses: statuses, state: state, db: db, token: token, started: time.Now()}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", a.handleStatus)
+	mux.HandleFunc("/healthz", a.handleHealthz)
+	mux.HandleFunc("/snooze", a.handleSnooze)
+	mux.HandleFunc("/unsnooze", a.handleUnsnooze)
+	mux.HandleFunc("/snoozes", a.handleSnoozes)
+	mux.HandleFunc("/stats", a.handleStats)
+	mux.HandleFunc("/history", a.handleHistory)
+	// Grafana simple-JSON datasource
+	mux.HandleFunc("/", a.handleGrafanaTest)
+	mux.HandleFunc("/search", a.handleGrafanaSearch)
+	mux.HandleFunc("/query", a.handleGrafanaQuery)
+
+	a.server = &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	return a
+}
+
+// authorized reports whether r may change snoozes, writing the error response
+// when it may not. With api_token set the request must carry it as a bearer
+// token; without one only clients on the same machine are trusted, since the
+// API usually listens on all interfaces.
+func (a *apiServer) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if a.token != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+		http.Error(w, "snoozing from other hosts requires api_token to be set", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// start serves in the background; a listen failure is logged, not fatal, so
+// monitoring keeps running without the API.
+func (a *apiServer) start() {
+	go func() {
+		slog.Info("API server listening", "addr", a.server.Addr)
+		if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("API server failed", "error", err)
+		}
+	}()
+}
+
+func (a *apiServer) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.server.Shutdown(ctx); err != nil {
+		slog.Warn("API server did not shut down cleanly", "error", err)
+	}
+}
+
+// handleStatus reports the latest result of every service, with its rolling
+// uptime over its recent results.
+func (a *apiServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	statuses, _ := a.statuses.list()
+	for i := range statuses {
+		if fraction, checks := a.state.uptime(statuses[i].ID); checks > 0 {
+			percent := fraction * 100
+			statuses[i].UptimePercent = &percent
+			statuses[i].UptimeChecks = checks
+		}
+	}
+	writeJSON(w, statuses)
+}
+
+// handleHealthz reports on the InfraPulse process itself, not the services it
+// monitors.
+func (a *apiServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	_, lastCycle := a.statuses.list()
+	health := struct {
+		Status    string     `json:"status"`
+		Uptime    string     `json:"uptime"`
+		LastCycle *time.Time `json:"last_cycle,omitempty"`
+	}{Status: "ok", Uptime: time.Since(a.started).Round(time.Second).String()}
+	if !lastCycle.IsZero() {
+		health.LastCycle = &lastCycle
+	}
+	writeJSON(w, health)
+}
+
+// handleSnooze suppresses alerts for a service until the snooze expires:
+// POST /snooze?service=<name or id>&duration=30m
+func (a *apiServer) handleSnooze(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorized(w, r) {
+		return
+	}
+	target := r.URL.Query().Get("service")
+	if target == "" {
+		http.Error(w, "missing service", http.StatusBadRequest)
+		return
+	}
+	d, err := time.ParseDuration(r.URL.Query().Get("duration"))
+	if err != nil || d <= 0 {
+		http.Error(w, "invalid duration", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, snooze{Service: target, Until: a.snoozes.snooze(target, d)})
+}
+
+// handleUnsnooze lifts a snooze early: POST /unsnooze?service=<name or id>
+func (a *apiServer) handleUnsnooze(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorized(w, r) {
+		return
+	}
+	if !a.snoozes.unsnooze(r.URL.Query().Get("service")) {
+		http.Error(w, "service is not snoozed", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSnoozes lists the active snoozes.
+func (a *apiServer) handleSnoozes(w http.ResponseWriter, r *http.Request) {
+	a.snoozes.expire()
+	writeJSON(w, a.snoozes.list())
+}
+
+// runStats is the JSON form of a runSummary, with durations in milliseconds.
+type runStats struct {
+	runSummary
+	WallTimeMs  float64 `json:"wall_time_ms"`
+	Slowest     string  `json:"slowest,omitempty"`
+	SlowestName string  `json:"slowest_name,omitempty"`
+	SlowestMs   float64 `json:"slowest_ms"`
+}
+
+// handleStats reports the aggregate stats of the latest check cycle.
+func (a *apiServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	summary := a.summaries.get()
+	if summary == nil {
+		http.Error(w, "no check cycle has completed yet", http.StatusServiceUnavailable)
+		return
+	}
+	stats := runStats{runSummary: *summary, WallTimeMs: milliseconds(summary.WallTime)}
+	if summary.Slowest != nil {
+		stats.Slowest = summary.Slowest.Service.ID()
+		stats.SlowestName = summary.Slowest.Service.Name
+		stats.SlowestMs = milliseconds(summary.Slowest.Duration)
+	}
+	writeJSON(w, stats)
+}
+
+// Defaults and bounds of GET /history.
+const (
+	historyDefaultSince = 24 * time.Hour
+	historyDefaultLimit = 100
+	historyMaxLimit     = 10000
+)
+
+// handleHistory returns stored results from the -db database, newest first:
+// GET /history?service=<name or id>&since=24h&limit=100. With a service, the
+// response also carries its uptime over the same period.
+func (a *apiServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if a.db == nil {
+		http.Error(w, "result history is not enabled, start InfraPulse with -db", http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	period := historyDefaultSince
+	if v := query.Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+		period = d
+	}
+	limit := historyDefaultLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > historyMaxLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", historyMaxLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	service := query.Get("service")
+	response := struct {
+		Service string     `json:"service,omitempty"`
+		Since   time.Time  `json:"since"`
+		Uptime  *float64   `json:"uptime,omitempty"` // Fraction of UP and WARN results
+		Checks  int        `json:"checks,omitempty"` // Results the uptime covers
+		Results []dbResult `json:"results"`
+	}{Service: service, Since: time.Now().Add(-period)}
+
+	var err error
+	if response.Results, err = a.db.recent(service, response.Since, limit); err != nil {
+		slog.Error("Failed to read result history", "error", err)
+		http.Error(w, "failed to read result history", http.StatusInternalServerError)
+		return
+	}
+	if service != "" {
+		uptime, checks, err := a.db.uptime(service, response.Since)
+		if err != nil {
+			slog.Error("Failed to read result history", "error", err)
+			http.Error(w, "failed to read result history", http.StatusInternalServerError)
+			return
+		}
+		if checks > 0 {
+			response.Uptime, response.Checks = &uptime, checks
+		}
+	}
+	writeJSON(w, response)
+}
+
+// handleGrafanaTest answers the datasource's "Save & Test" connection check.
+func (a *apiServer) handleGrafanaTest(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleGrafanaSearch lists the queryable targets: a status and a latency
+// series for every service with recorded history.
+func (a *apiServer) handleGrafanaSearch(w http.ResponseWriter, r *http.Request) {
+	var targets []string
+	for _, serviceID := range a.history.serviceIDs() {
+		targets = append(targets, serviceID+grafanaStatusSuffix, serviceID+grafanaLatencySuffix)
+	}
+	sort.Strings(targets)
+	writeJSON(w, targets)
+}
+
+type grafanaQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+	} `json:"targets"`
+}
+
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"` // [value, unix ms]
+}
+
+// handleGrafanaQuery returns time series for the requested targets. Status is
+// 1 for UP and 0 otherwise; latency is in milliseconds.
+func (a *apiServer) handleGrafanaQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var query grafanaQuery
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		http.Error(w, "invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	series := []grafanaSeries{}
+	for _, t := range query.Targets {
+		serviceID, latency := strings.CutSuffix(t.Target, grafanaLatencySuffix)
+		if !latency {
+			var ok bool
+			if serviceID, ok = strings.CutSuffix(t.Target, grafanaStatusSuffix); !ok {
+				continue
+			}
+		}
+
+		s := grafanaSeries{Target: t.Target, Datapoints: [][2]float64{}}
+		for _, p := range a.history.between(serviceID, query.Range.From, query.Range.To) {
+			value := 0.0
+			if latency {
+				value = milliseconds(p.Latency)
+			} else if p.Status == StatusUp {
+				value = 1
+			}
+			s.Datapoints = append(s.Datapoints, [2]float64{value, float64(p.Time.UnixMilli())})
+		}
+		series = append(series, s)
+	}
+	writeJSON(w, series)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("Failed to write API response", "error", err)
+	}
+}
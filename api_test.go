@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandleSnoozeAuthorization(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		remoteAddr string
+		header     string
+		want       int
+	}{
+		{"no token, loopback", "", "127.0.0.1:50000", "", http.StatusOK},
+		{"no token, IPv6 loopback", "", "[::1]:50000", "", http.StatusOK},
+		{"no token, remote", "", "192.0.2.10:50000", "", http.StatusForbidden},
+		{"token, missing", "s3cret", "127.0.0.1:50000", "", http.StatusUnauthorized},
+		{"token, wrong", "s3cret", "192.0.2.10:50000", "Bearer nope", http.StatusUnauthorized},
+		{"token, not bearer", "s3cret", "192.0.2.10:50000", "s3cret", http.StatusUnauthorized},
+		{"token, remote", "s3cret", "192.0.2.10:50000", "Bearer s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &apiServer{snoozes: newSnoozeStore(), token: tt.token}
+			req := httptest.NewRequest(http.MethodPost, "/snooze?service=db&duration=30m", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			a.handleSnooze(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body)
+			}
+			if snoozed := len(a.snoozes.list()) == 1; snoozed != (tt.want == http.StatusOK) {
+				t.Errorf("snoozed = %v after status %d", snoozed, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHandleUnsnoozeRequiresToken(t *testing.T) {
+	a := &apiServer{snoozes: newSnoozeStore(), token: "s3cret"}
+	a.snoozes.snooze("db", time.Hour)
+	req := httptest.NewRequest(http.MethodPost, "/unsnooze?service=db", nil)
+	rec := httptest.NewRecorder()
+	a.handleUnsnooze(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if len(a.snoozes.list()) != 1 {
+		t.Error("snooze was lifted without the token")
+	}
+}
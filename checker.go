@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Checker runs one attempt of a service's check. Each check type has its own
+// Checker, and newChecker picks the one a service's definition calls for, so
+// a new check type is a Checker and a case in newChecker.
+type Checker interface {
+	Check(ctx context.Context) CheckResult
+}
+
+// newChecker returns the Checker for service. Where a port combines options,
+// the first match wins: a tcp_script over TLS, for example, runs the script.
+func newChecker(service Service) Checker {
+	switch {
+	case service.Exec != nil:
+		return ExecChecker{service}
+	case service.Port == 0:
+		return PingChecker{service}
+	case service.UDP:
+		return UDPChecker{service}
+	case service.STUN != nil:
+		return STUNChecker{service}
+	case service.DNS != nil:
+		return DNSChecker{service}
+	case service.SNMP != nil:
+		return SNMPChecker{service}
+	case service.MX != nil:
+		return MXChecker{service}
+	case service.HTTP != nil:
+		return HTTPChecker{service}
+	case service.TCPScript != nil:
+		return TCPScriptChecker{service}
+	case service.TCPProbe != nil:
+		return TCPProbeChecker{service}
+	case service.GRPCHealth:
+		return GRPCHealthChecker{service}
+	case service.GRPCReflection != nil:
+		return GRPCReflectionChecker{service}
+	case service.TLS:
+		return TLSChecker{service}
+	case service.SYN:
+		return SYNChecker{service}
+	default:
+		return TCPChecker{service}
+	}
+}
+
+// closeOnCancel closes conn once ctx is cancelled, interrupting a read or
+// write that would otherwise block until the connection's deadline. Calling
+// the returned function stops watching.
+func closeOnCancel(ctx context.Context, conn io.Closer) (stop func() bool) {
+	return context.AfterFunc(ctx, func() { conn.Close() })
+}
+
+// errPortOpen fails a port that is expected to be closed.
+var errPortOpen = errors.New("port unexpectedly open")
+
+// dialClosed reports whether a failed dial shows the port is not exposed:
+// the host refused the connection, or a firewall dropped it and it timed out.
+func dialClosed(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, syscall.ECONNREFUSED) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// TCPChecker connects to a port and closes the connection again.
+type TCPChecker struct{ Service Service }
+
+func (c TCPChecker) Check(ctx context.Context) CheckResult {
+	service := c.Service
+	address := net.JoinHostPort(service.Host, strconv.Itoa(service.Port))
+	dialStart := time.Now()
+	conn, err := service.dialer("tcp", service.Timeout).DialContext(ctx, "tcp", address)
+	latency := time.Since(dialStart)
+	if service.ExpectClosed {
+		switch {
+		case err == nil:
+			conn.Close()
+			return CheckResult{Service: service, Status: StatusDown, Error: errPortOpen}
+		case dialClosed(err):
+			return CheckResult{Service: service, Status: StatusUp}
+		}
+		// A host that doesn't resolve, or a dial that failed locally, says
+		// nothing about the port.
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("could not tell whether the port is closed: %w", err)}
+	}
+	if err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	conn.Close()
+	return CheckResult{Service: service, Status: StatusUp, Latency: latency}
+}
+
+// Errors of synProbe the SYN checker tells apart. errSYNUnsupported and
+// errSYNIPv6 mean the probe can't run; errSYNRefused and errSYNNoReply that
+// the port is not accepting connections.
+var (
+	errSYNUnsupported = errors.New("SYN check is only supported on Linux (requires raw sockets and CAP_NET_RAW)")
+	errSYNIPv6        = errors.New("SYN check supports IPv4 targets only")
+	errSYNRefused     = errors.New("connection refused")
+	errSYNNoReply     = errors.New("no SYN-ACK")
+)
+
+// SYNChecker probes a port with a half-open SYN, for servers with syn set.
+// Without the privilege, or on a platform without SYN probes, the check can't
+// run and is UNKNOWN rather than DOWN.
+type SYNChecker struct{ Service Service }
+
+func (c SYNChecker) Check(ctx context.Context) CheckResult {
+	service := c.Service
+	err := synProbe(ctx, service.Host, service.Port, service.Timeout)
+	switch {
+	case errors.Is(err, os.ErrPermission), errors.Is(err, errSYNUnsupported), errors.Is(err, errSYNIPv6):
+		return CheckResult{Service: service, Status: StatusUnknown, Error: err}
+	case service.ExpectClosed && err == nil:
+		return CheckResult{Service: service, Status: StatusDown, Error: errPortOpen}
+	case service.ExpectClosed && (errors.Is(err, errSYNRefused) || errors.Is(err, errSYNNoReply)):
+		return CheckResult{Service: service, Status: StatusUp}
+	case service.ExpectClosed:
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("could not tell whether the port is closed: %w", err)}
+	case err != nil:
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	return CheckResult{Service: service, Status: StatusUp}
+}
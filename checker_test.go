@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCPCheckerExpectClosed(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	openPort := listener.Addr().(*net.TCPAddr).Port
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+	defer listener.Close()
+
+	tests := []struct {
+		name string
+		host string
+		port int
+		want Status
+	}{
+		{"open", "127.0.0.1", openPort, StatusDown},
+		{"refused", "127.0.0.1", closedPort, StatusUp},
+		{"unresolvable host", "no-such-host.invalid", closedPort, StatusDown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := Service{Name: tt.name, Host: tt.host, Port: tt.port, ExpectClosed: true, Timeout: 2 * time.Second}
+			if got := (TCPChecker{service}).Check(context.Background()); got.Status != tt.want {
+				t.Errorf("Check() = %s (%v), want %s", got.Status, got.Error, tt.want)
+			}
+		})
+	}
+}
+
+func TestSYNCheckerIPv6IsUnknown(t *testing.T) {
+	service := Service{Name: "v6", Host: "::1", Port: 443, SYN: true, ExpectClosed: true, Timeout: time.Second}
+	if got := (SYNChecker{service}).Check(context.Background()); got.Status != StatusUnknown {
+		t.Errorf("Check() = %s (%v), want %s", got.Status, got.Error, StatusUnknown)
+	}
+}
+
+func TestTCPDialogCheckersReportLatency(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 4)
+				if _, err := io.ReadFull(conn, buf); err == nil {
+					conn.Write([]byte("pong"))
+				}
+			}()
+		}
+	}()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	service := Service{Name: "game", Host: "127.0.0.1", Port: port, Timeout: 2 * time.Second}
+	probe := service
+	probe.TCPProbe = &TCPProbeCheck{ResponseBytes: 4, payload: []byte("ping")}
+	script := service
+	script.TCPScript = &TCPScriptCheck{Steps: []ScriptStep{{Send: "ping", Expect: "pong"}}}
+	for _, checker := range []Checker{TCPProbeChecker{probe}, TCPScriptChecker{script}} {
+		got := checker.Check(context.Background())
+		if got.Status != StatusUp {
+			t.Errorf("%T: Check() = %s (%v), want UP", checker, got.Status, got.Error)
+		}
+		if got.Latency <= 0 {
+			t.Errorf("%T: Check() latency = %s, want the measured reply time", checker, got.Latency)
+		}
+	}
+}
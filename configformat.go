@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// isJSONConfig reports whether a config file is JSON, going by its extension.
+// Anything other than .json is read as YAML.
+func isJSONConfig(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+// unmarshalConfig decodes a YAML or JSON config file into v. JSON is a subset
+// of YAML, so both formats are decoded by the YAML decoder and share the yaml
+// field names and custom unmarshalers. A .json file is checked to be valid
+// JSON first, so a stray YAML construct in it is reported rather than
+// silently accepted.
+//
+// A file that sets expand_env: true has environment variables in its values
+// expanded before decoding, see expandEnv, and files in an older format
+// version are migrated, see migrateConfig.
+func unmarshalConfig(path string, data []byte, v any) error {
+	if isJSONConfig(path) {
+		var doc any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+	if root.Kind == 0 { // Empty file
+		return nil
+	}
+	expand, err := envExpansion(&root)
+	if err != nil {
+		return err
+	}
+	if expand {
+		if err := expandEnv(&root); err != nil {
+			return err
+		}
+	}
+	if err := migrateConfig(path, &root); err != nil {
+		return err
+	}
+	var unknown []string
+	unknownKeys(&root, reflect.TypeOf(v), "", &unknown)
+	if len(unknown) > 0 {
+		return errors.New(strings.Join(unknown, "; "))
+	}
+	return root.Decode(v)
+}
+
+// unknownKeys reports every mapping key under node that has no field in t,
+// such as a misspelled timout:, which the decoder would silently ignore.
+// Types with their own unmarshaler are checked against their fields too, as
+// they all decode a mapping into a plain copy of themselves.
+func unknownKeys(node *yaml.Node, t reflect.Type, path string, unknown *[]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			unknownKeys(child, t, path, unknown)
+		}
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return
+		}
+		for i, child := range node.Content {
+			unknownKeys(child, t.Elem(), fmt.Sprintf("%s[%d]", path, i), unknown)
+		}
+	case yaml.MappingNode:
+		switch t.Kind() {
+		case reflect.Map:
+			for i := 1; i < len(node.Content); i += 2 {
+				unknownKeys(node.Content[i], t.Elem(), joinKeyPath(path, node.Content[i-1].Value), unknown)
+			}
+		case reflect.Struct:
+			fields := yamlFields(t)
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i]
+				field, ok := fields[key.Value]
+				if !ok {
+					where := ""
+					if path != "" {
+						where = " in " + path
+					}
+					*unknown = append(*unknown, fmt.Sprintf("line %d: unknown key %q%s", key.Line, key.Value, where))
+					continue
+				}
+				unknownKeys(node.Content[i+1], field.Type, joinKeyPath(path, key.Value), unknown)
+			}
+		}
+	}
+}
+
+// yamlFields maps the yaml keys of t's exported fields to the fields.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		switch key {
+		case "-":
+			continue
+		case "":
+			key = strings.ToLower(field.Name)
+		}
+		fields[key] = field
+	}
+	return fields
+}
+
+func joinKeyPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// envExpansion reads and removes the top-level expand_env setting, which
+// turns on environment variable expansion for the file. It is off by
+// default, so a literal $ in an existing password or URL keeps working.
+func envExpansion(root *yaml.Node) (bool, error) {
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return false, nil
+	}
+	doc := root.Content[0]
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value != "expand_env" {
+			continue
+		}
+		value := doc.Content[i+1]
+		var expand bool
+		if err := value.Decode(&expand); err != nil {
+			return false, fmt.Errorf("line %d: expand_env must be true or false", value.Line)
+		}
+		doc.Content = slices.Delete(doc.Content, i, i+2)
+		return expand, nil
+	}
+	return false, nil
+}
+
+// expandEnv replaces ${VAR} and $VAR in every scalar value under node with
+// the environment variable's value; keys are left alone. An unset variable is
+// an error rather than an empty string, so a missing variable can't silently
+// blank a host or password. $$ stands for a literal $. An unquoted value is
+// re-typed after expansion, so port: ${SMTP_PORT} still decodes as a number.
+// Go template actions, {{ ... }}, are left as they are, so template variables
+// such as {{range $i, $a := .Alerts}} keep working.
+func expandEnv(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := expandEnv(child); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := expandEnv(node.Content[i]); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "$") {
+			return nil
+		}
+		var undefined []string
+		value, err := expandOutsideActions(node.Value, func(name string) string {
+			switch {
+			case name == "$":
+				return "$"
+			case !isEnvName(name): // $1, $? and the like, as in a regex
+				return "$" + name
+			}
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				undefined = append(undefined, name)
+			}
+			return value
+		})
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		node.Value = value
+		if len(undefined) > 0 {
+			return fmt.Errorf("line %d: environment variable %s is not set", node.Line, strings.Join(undefined, ", "))
+		}
+		if node.Style == 0 {
+			node.Tag = ""
+		}
+	}
+	return nil
+}
+
+// expandOutsideActions is os.Expand for the text of s outside {{ ... }}
+// template actions. An action that is never closed runs to the end of s.
+func expandOutsideActions(s string, mapping func(string) string) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			start = len(s)
+		}
+		if err := checkEnvBraces(s[:start]); err != nil {
+			return "", err
+		}
+		b.WriteString(os.Expand(s[:start], mapping))
+		if start == len(s) {
+			return b.String(), nil
+		}
+		end := strings.Index(s[start+2:], "}}")
+		if end < 0 {
+			b.WriteString(s[start:])
+			return b.String(), nil
+		}
+		end += start + 4
+		b.WriteString(s[start:end])
+		s = s[end:]
+	}
+}
+
+// checkEnvBraces rejects a ${ without its closing brace, or with nothing in
+// between, which os.Expand would silently drop.
+func checkEnvBraces(s string) error {
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] != '$' {
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			i++
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return errors.New("unterminated ${, write $$ for a literal $")
+			}
+			if end == 2 {
+				return errors.New("empty ${}, write $$ for a literal $")
+			}
+			i += end
+		}
+	}
+	return nil
+}
+
+// configDir returns the directory holding the configuration: -config itself
+// when it names a directory, otherwise the directory of the server file.
+func configDir(serverFile string) string {
+	if info, err := os.Stat(serverFile); err == nil && info.IsDir() {
+		return serverFile
+	}
+	return filepath.Dir(serverFile)
+}
+
+// privateConfigFile returns the path of the private config file next to
+// serverFile, config.json for a JSON server list and config.yaml otherwise.
+// In a config directory config.json is used if it exists.
+func privateConfigFile(serverFile string) string {
+	name := "config.yaml"
+	if isJSONConfig(serverFile) {
+		name = "config.json"
+	}
+	dir := configDir(serverFile)
+	if dir == serverFile {
+		if _, err := os.Stat(filepath.Join(dir, "config.json")); err == nil {
+			name = "config.json"
+		}
+	}
+	return filepath.Join(dir, name)
+}
+
+// serverFiles returns the server files to load for -config: the file itself,
+// or every YAML and JSON file in the directory it names, by file name, except
+// the private config. The first file is read as the main server file.
+func serverFiles(path string) ([]string, error) {
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config directory %s: %w", path, err)
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == "config.yaml" || name == "config.json" {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".yaml", ".yml", ".json":
+			files = append(files, filepath.Join(path, name))
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no server files (*.yaml, *.yml, *.json) in config directory %s", path)
+	}
+	return files, nil
+}
+
+// isEnvName reports whether name is a valid environment variable name.
+func isEnvName(name string) bool {
+	for i, c := range name {
+		if c != '_' && !unicode.IsLetter(c) && (i == 0 || !unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return name != ""
+}
@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+func TestUnmarshalConfigLeavesTemplateVariables(t *testing.T) {
+	t.Setenv("INFRAPULSE_TEST_SITE", "example.com")
+	data := []byte(`expand_env: true
+email_subject_template: "{{ $n := len .Alerts }}{{ $n }} alerts on $INFRAPULSE_TEST_SITE"
+email_body_template: |
+  {{range $i, $a := .Alerts}}{{if $i}},{{end}}{{$a.Service}}{{end}}
+dedup_key_template: "${INFRAPULSE_TEST_SITE}/{{ .Service.Name }}"
+`)
+	var cfg Config
+	if err := unmarshalConfig("config.yaml", data, &cfg); err != nil {
+		t.Fatalf("unmarshalConfig: %v", err)
+	}
+	if want := "{{ $n := len .Alerts }}{{ $n }} alerts on example.com"; cfg.EmailSubjectTemplate != want {
+		t.Errorf("email_subject_template = %q, want %q", cfg.EmailSubjectTemplate, want)
+	}
+	if want := "{{range $i, $a := .Alerts}}{{if $i}},{{end}}{{$a.Service}}{{end}}\n"; cfg.EmailBodyTemplate != want {
+		t.Errorf("email_body_template = %q, want %q", cfg.EmailBodyTemplate, want)
+	}
+	if want := "example.com/{{ .Service.Name }}"; cfg.DedupKeyTemplate != want {
+		t.Errorf("dedup_key_template = %q, want %q", cfg.DedupKeyTemplate, want)
+	}
+	if _, err := parseEmailTemplate("email_body_template", cfg.EmailBodyTemplate); err != nil {
+		t.Errorf("email_body_template does not parse: %v", err)
+	}
+}
+
+func TestUnmarshalConfigUnsetVariableOutsideTemplate(t *testing.T) {
+	data := []byte("expand_env: true\n" + `email_subject_template: "{{ $n := len .Alerts }} $INFRAPULSE_TEST_UNSET"` + "\n")
+	var cfg Config
+	if err := unmarshalConfig("config.yaml", data, &cfg); err == nil {
+		t.Fatal("unmarshalConfig succeeded with an unset variable outside the template action")
+	}
+}
+
+func TestUnmarshalConfigEnvExpansion(t *testing.T) {
+	t.Setenv("INFRAPULSE_TEST_HOST", "smtp.example.com")
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{"off by default", `smtp: {host: "$INFRAPULSE_TEST_HOST", password: "pa$$word${"}`, "$INFRAPULSE_TEST_HOST pa$$word${", false},
+		{"off", "expand_env: false\n" + `smtp: {host: "${INFRAPULSE_TEST_HOST}", password: "p$w"}`, "${INFRAPULSE_TEST_HOST} p$w", false},
+		{"on", "expand_env: true\n" + `smtp: {host: "${INFRAPULSE_TEST_HOST}", password: "pa$$word"}`, "smtp.example.com pa$word", false},
+		{"unset variable", "expand_env: true\n" + `smtp: {host: "${INFRAPULSE_TEST_UNSET}"}`, "", true},
+		{"unterminated brace", "expand_env: true\n" + `smtp: {password: "pa${ss"}`, "", true},
+		{"empty braces", "expand_env: true\n" + `smtp: {password: "pa${}ss"}`, "", true},
+		{"not a bool", "expand_env: yes please\n", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			err := unmarshalConfig("config.yaml", []byte(tt.data), &cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("unmarshalConfig succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unmarshalConfig: %v", err)
+			}
+			if got := cfg.SMTP.Host + " " + cfg.SMTP.Password; got != tt.want {
+				t.Errorf("host and password = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
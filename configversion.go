@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configMigrations upgrade a config file's document from one format version
+// to the next: configMigrations[i] turns version i+1 into version i+2. A
+// change that would break existing files, such as renaming or restructuring
+// a field, appends a migration here, which makes the new format the current
+// version while files in the old one keep loading.
+var configMigrations []func(doc *yaml.Node) error
+
+// currentConfigVersion is the config format version this build writes and
+// reads natively. Files without a version are version 1, the format from
+// before versioning.
+var currentConfigVersion = len(configMigrations) + 1
+
+// migrateConfig reads and removes a config file's top-level version and
+// upgrades the document to the current version. A version newer than this build knows is
+// an error, since its fields may mean something this build would misread.
+func migrateConfig(path string, root *yaml.Node) error {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	doc := root.Content[0]
+
+	version := 1
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value != "version" {
+			continue
+		}
+		value := doc.Content[i+1]
+		v, err := strconv.Atoi(value.Value)
+		if err != nil || value.Kind != yaml.ScalarNode {
+			return fmt.Errorf("line %d: version must be a whole number, such as %d", value.Line, currentConfigVersion)
+		}
+		version = v
+		// version is not a setting, and would otherwise be an unknown key
+		// to the decoder.
+		doc.Content = slices.Delete(doc.Content, i, i+2)
+		break
+	}
+
+	switch {
+	case version < 1:
+		return fmt.Errorf("version %d is not a valid config version, the first is 1", version)
+	case version > currentConfigVersion:
+		return fmt.Errorf("config version %d is newer than this InfraPulse supports (up to %d); please upgrade InfraPulse", version, currentConfigVersion)
+	case version < currentConfigVersion:
+		for v := version; v < currentConfigVersion; v++ {
+			if err := configMigrations[v-1](doc); err != nil {
+				return fmt.Errorf("failed to migrate from config version %d to %d: %w", v, v+1, err)
+			}
+		}
+		slog.Warn("Config file uses an older format and was migrated in memory; please upgrade it to the current version", "file", path, "version", version, "current", currentConfigVersion)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"no version", "timeout: 5s\n", ""},
+		{"current version", "version: 1\ntimeout: 5s\n", ""},
+		{"newer version", "version: 2\ntimeout: 5s\n", "newer than this InfraPulse supports"},
+		{"version zero", "version: 0\n", "not a valid config version"},
+		{"not a number", "version: one\n", "line 1: version must be a whole number"},
+		{"not a scalar", "version: [1]\n", "version must be a whole number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var root yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.data), &root); err != nil {
+				t.Fatal(err)
+			}
+			err := migrateConfig("servers.yaml", &root)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("migrateConfig = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("migrateConfig: %v", err)
+			}
+			var cfg Config
+			if err := root.Decode(&cfg); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if cfg.Timeout != "5s" {
+				t.Errorf("timeout = %q, want 5s", cfg.Timeout)
+			}
+			for i := 0; i < len(root.Content[0].Content); i += 2 {
+				if key := root.Content[0].Content[i].Value; key == "version" {
+					t.Error("version was left in the document")
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateConfigRunsMigrations(t *testing.T) {
+	migrations, current := configMigrations, currentConfigVersion
+	t.Cleanup(func() { configMigrations, currentConfigVersion = migrations, current })
+	// Version 2 renamed check_timeout to timeout.
+	configMigrations = []func(doc *yaml.Node) error{func(doc *yaml.Node) error {
+		for i := 0; i < len(doc.Content); i += 2 {
+			if doc.Content[i].Value == "check_timeout" {
+				doc.Content[i].Value = "timeout"
+			}
+		}
+		return nil
+	}}
+	currentConfigVersion = 2
+
+	for _, data := range []string{"check_timeout: 5s\n", "version: 1\ncheck_timeout: 5s\n", "version: 2\ntimeout: 5s\n"} {
+		var cfg Config
+		if err := unmarshalConfig("servers.yaml", []byte(data), &cfg); err != nil {
+			t.Errorf("unmarshalConfig(%q): %v", data, err)
+			continue
+		}
+		if cfg.Timeout != "5s" {
+			t.Errorf("unmarshalConfig(%q): timeout = %q, want 5s", data, cfg.Timeout)
+		}
+	}
+}
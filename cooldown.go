@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// alertCooldown holds back repeat alerts for a service during a network
+// storm. Once a service has alerted, its further alerts within the cooldown
+// are held, and when the cooldown ends the latest one is sent in that
+// cycle's batch if it still describes the service, so a flapping service
+// produces one alert per cooldown rather than one per cycle.
+type alertCooldown struct {
+	lastSent map[string]time.Time
+	lastKind map[string]string // Kind of the last alert sent per service
+	held     map[string]heldAlert
+}
+
+type heldAlert struct {
+	alert Alert
+	count int // Alerts held since the last one sent
+}
+
+func newAlertCooldown() *alertCooldown {
+	return &alertCooldown{lastSent: make(map[string]time.Time), lastKind: make(map[string]string), held: make(map[string]heldAlert)}
+}
+
+// filter returns the alerts to send now: the new alerts of services outside
+// their cooldown, followed by held alerts whose cooldown has ended. status
+// reports a service's current status, so a held alert the service has since
+// moved past (a DOWN alert for a service that is UP again) is dropped, and so
+// is a recovery of an outage whose DOWN alert was never sent. Self-monitoring
+// alerts are not per service and always pass.
+func (c *alertCooldown) filter(alerts []Alert, period time.Duration, now time.Time, status func(serviceID string) Status) []Alert {
+	if period <= 0 {
+		return alerts
+	}
+
+	var send []Alert
+	for _, alert := range alerts {
+		if alert.Kind == "self" {
+			send = append(send, alert)
+			continue
+		}
+		serviceID := alert.Result.Service.ID()
+		if now.Sub(c.lastSent[serviceID]) < period {
+			c.held[serviceID] = heldAlert{alert: alert, count: c.held[serviceID].count + 1}
+			continue
+		}
+		if c.unannounced(alert) {
+			// The outage began and ended unreported, a held DOWN alert
+			// included, so there is nothing to recover from.
+			delete(c.held, serviceID)
+			continue
+		}
+		send = append(send, alert)
+		c.sent(alert, now)
+	}
+
+	for _, serviceID := range slices.Sorted(maps.Keys(c.held)) {
+		h := c.held[serviceID]
+		if now.Sub(c.lastSent[serviceID]) < period {
+			continue
+		}
+		delete(c.held, serviceID)
+		if !stillCurrent(h.alert, status(serviceID)) || c.unannounced(h.alert) {
+			continue
+		}
+		h.alert.Message += fmt.Sprintf("\nHeld back by alert_cooldown (%s): %d alert(s) for this service since the last one sent.\n", period, h.count)
+		send = append(send, h.alert)
+		c.sent(h.alert, now)
+	}
+	return send
+}
+
+func (c *alertCooldown) sent(alert Alert, now time.Time) {
+	serviceID := alert.Result.Service.ID()
+	c.lastSent[serviceID] = now
+	c.lastKind[serviceID] = alert.Kind
+}
+
+// unannounced reports whether alert is a recovery of an outage the cooldown
+// never sent a DOWN alert for: the last alert sent for the service was
+// something else. A service that has sent nothing yet, e.g. one DOWN since
+// before a restart, is given the benefit of the doubt.
+func (c *alertCooldown) unannounced(alert Alert) bool {
+	kind, ok := c.lastKind[alert.Result.Service.ID()]
+	return alert.Kind == "recovery" && ok && kind != "down"
+}
+
+// stillCurrent reports whether a held alert still matches the service's
+// status.
+func stillCurrent(alert Alert, status Status) bool {
+	switch alert.Kind {
+	case "down":
+		return status == StatusDown
+	case "recovery":
+		return status == StatusUp
+	case "degraded":
+		return status == StatusWarn
+	default:
+		return true
+	}
+}
+
+// retain forgets the services whose ID is not in ids.
+func (c *alertCooldown) retain(ids map[string]bool) {
+	maps.DeleteFunc(c.lastSent, func(id string, _ time.Time) bool { return !ids[id] })
+	maps.DeleteFunc(c.lastKind, func(id string, _ string) bool { return !ids[id] })
+	maps.DeleteFunc(c.held, func(id string, _ heldAlert) bool { return !ids[id] })
+}
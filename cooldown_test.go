@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlertCooldownRecoveryOfUnsentOutage(t *testing.T) {
+	service := Service{Name: "web", Host: "192.0.2.1", Port: 443}
+	alert := func(kind string) Alert { return Alert{Kind: kind, Result: CheckResult{Service: service}} }
+	status := StatusUp
+	current := func(string) Status { return status }
+
+	c := newAlertCooldown()
+	start := time.Now()
+	steps := []struct {
+		after  time.Duration
+		alerts []Alert
+		status Status
+		want   []string
+	}{
+		{0, []Alert{alert("down")}, StatusDown, []string{"down"}},
+		{70 * time.Second, []Alert{alert("recovery")}, StatusUp, []string{"recovery"}},
+		// A new outage within the cooldown is held, then ends before it is sent.
+		{80 * time.Second, []Alert{alert("down")}, StatusDown, nil},
+		{90 * time.Second, []Alert{alert("recovery")}, StatusUp, nil},
+		// Neither the DOWN alert nor its recovery go out.
+		{140 * time.Second, nil, StatusUp, nil},
+		{150 * time.Second, []Alert{alert("down")}, StatusDown, []string{"down"}},
+		// A recovery held after a DOWN alert that was sent still goes out.
+		{160 * time.Second, []Alert{alert("recovery")}, StatusUp, nil},
+		{220 * time.Second, nil, StatusUp, []string{"recovery"}},
+	}
+	for i, step := range steps {
+		status = step.status
+		var got []string
+		for _, a := range c.filter(step.alerts, time.Minute, start.Add(step.after), current) {
+			got = append(got, a.Kind)
+		}
+		if len(got) != len(step.want) || (len(got) > 0 && got[0] != step.want[0]) {
+			t.Fatalf("step %d: sent %v, want %v", i, got, step.want)
+		}
+	}
+}
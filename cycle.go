@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// runCycleChecks runs the checks of one monitoring loop cycle, passing each
+// result to each as it arrives. Checks still running when timeout expires are
+// abandoned and reported DOWN, and checks that had not started yet (later
+// stages, or ones waiting for a worker slot) are reported SKIPPED, so a few
+// hung services can't stretch a cycle past the next tick. Abandoned checks
+// are cancelled and stop at their next network operation; see skipInFlight.
+// On shutdown, ctx is cancelled and unfinished checks are not reported. Each
+// check's start is delayed by up to jitter, which counts against timeout.
+func runCycleChecks(ctx context.Context, pool *checkPool, due []Service, inFlight *inFlightChecks, timeout, jitter time.Duration, each func(CheckResult)) []CheckResult {
+	cycleCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	stream := pool.runStages(cycleCtx, due, inFlight, jitter)
+	var results []CheckResult
+	reported := make(map[Service]bool, len(due))
+collect:
+	for {
+		select {
+		case result, ok := <-stream:
+			if !ok {
+				break collect
+			}
+			each(result)
+			results = append(results, result)
+			reported[result.Service] = true
+		case <-cycleCtx.Done():
+			break collect
+		}
+	}
+	if ctx.Err() != nil || !errors.Is(cycleCtx.Err(), context.DeadlineExceeded) {
+		return results
+	}
+
+	abandoned := 0
+	for _, service := range due {
+		if reported[service] {
+			continue
+		}
+		result := CheckResult{Service: service, Time: start, Duration: timeout, Status: StatusSkipped, Error: fmt.Errorf("not started within cycle_timeout %s", timeout)}
+		if inFlight.has(service.ID()) {
+			result.Status = StatusDown
+			result.Error = fmt.Errorf("check did not finish within cycle_timeout %s", timeout)
+			result.Latency = timeout
+			abandoned++
+		}
+		each(result)
+		results = append(results, result)
+	}
+	slog.Warn("Cycle timed out, unfinished checks abandoned", "cycle_timeout", timeout, "abandoned", abandoned, "unfinished", len(due)-len(reported))
+	return results
+}
+
+// skipInFlight drops the services whose check from an earlier, timed-out
+// cycle is still running, so a hung service never has two checks in flight.
+// They are checked again on their next tick after the old check returns.
+func skipInFlight(due []Service, inFlight *inFlightChecks) []Service {
+	var ready []Service
+	var skipped []string
+	for _, service := range due {
+		if inFlight.has(service.ID()) {
+			skipped = append(skipped, service.ID())
+			continue
+		}
+		ready = append(ready, service)
+	}
+	if len(skipped) > 0 {
+		slog.Warn("Checks from the previous cycle still running, skipping them this tick", "services", skipped)
+	}
+	return ready
+}
+
+// mergeRerun replaces the results of first that were checked again in rerun.
+// Services left out of the re-run, such as those whose first check is still
+// in flight, keep their first result, so they are still recorded and alerted
+// on.
+func mergeRerun(first, rerun []CheckResult) []CheckResult {
+	again := make(map[string]CheckResult, len(rerun))
+	for _, result := range rerun {
+		again[result.Service.ID()] = result
+	}
+	merged := make([]CheckResult, 0, len(first))
+	for _, result := range first {
+		if rerunResult, ok := again[result.Service.ID()]; ok {
+			result = rerunResult
+		}
+		merged = append(merged, result)
+	}
+	return merged
+}
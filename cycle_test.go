@@ -0,0 +1,30 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMergeRerun(t *testing.T) {
+	db := Service{Name: "db", Host: "db.example.com", Port: 5432}
+	web := Service{Name: "web", Host: "web.example.com", Port: 443}
+	hung := Service{Name: "hung", Host: "hung.example.com", Port: 22}
+	first := []CheckResult{
+		{Service: db, Status: StatusDown},
+		{Service: hung, Status: StatusDown},
+		{Service: web, Status: StatusDown},
+	}
+	// hung is still in flight, so the re-run leaves it out.
+	rerun := []CheckResult{
+		{Service: web, Status: StatusUp},
+		{Service: db, Status: StatusUp},
+	}
+
+	var got []string
+	for _, result := range mergeRerun(first, rerun) {
+		got = append(got, result.Service.Name+" "+string(result.Status))
+	}
+	if want := []string{"db UP", "hung DOWN", "web UP"}; !slices.Equal(got, want) {
+		t.Errorf("mergeRerun = %q, want %q", got, want)
+	}
+}
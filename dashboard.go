@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+)
+
+// dashboardClear moves the cursor home and clears the screen, so each cycle
+// redraws the dashboard in place.
+const dashboardClear = "\033[H\033[2J"
+
+// dashboardErrorWidth caps the error column so one verbose error doesn't wrap
+// every row.
+const dashboardErrorWidth = 60
+
+// dashboardRow is the last result of a service and when its status last
+// changed.
+type dashboardRow struct {
+	result  CheckResult
+	changed time.Time
+}
+
+// dashboard renders a table of every service, redrawn after each cycle in
+// monitoring loop mode with -dashboard, instead of a line per result.
+type dashboard struct {
+	out  io.Writer
+	rows map[string]dashboardRow
+}
+
+// newDashboard returns a dashboard writing to stdout, or nil if stdout is not
+// a terminal, in which case the loop keeps its line output.
+func newDashboard() *dashboard {
+	if !isatty.IsTerminal(os.Stdout.Fd()) && !isatty.IsCygwinTerminal(os.Stdout.Fd()) {
+		return nil
+	}
+	return &dashboard{out: os.Stdout, rows: make(map[string]dashboardRow)}
+}
+
+// observe is the per-result callback of a cycle in dashboard mode. Results
+// are shown when the cycle is rendered, so it only records them in the log
+// file.
+func (d *dashboard) observe(result CheckResult) {
+	if logToFile {
+		logResult(result)
+	}
+}
+
+// update records a cycle's results. Skipped checks did not run and leave the
+// service's row as it was.
+func (d *dashboard) update(results []CheckResult) {
+	for _, result := range results {
+		if result.Status == StatusSkipped {
+			continue
+		}
+		id := result.Service.ID()
+		row, ok := d.rows[id]
+		if !ok || row.result.Status != result.Status {
+			row.changed = result.Time
+		}
+		row.result = result
+		d.rows[id] = row
+	}
+}
+
+// retain drops the rows of services whose ID is not in ids.
+func (d *dashboard) retain(ids map[string]bool) {
+	for id := range d.rows {
+		if !ids[id] {
+			delete(d.rows, id)
+		}
+	}
+}
+
+// render clears the screen and draws every service in config order, with
+// the cycle's summary above them. Services not checked yet show as PENDING.
+func (d *dashboard) render(services []Service, summary runSummary, state *stateStore) {
+	var table bytes.Buffer
+	w := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tSERVICE\tTARGET\tCHECK\tLATENCY\tUPTIME\tCHANGED\tERROR")
+	rowColors := make([]*color.Color, 0, len(services))
+	for _, service := range services {
+		row, ok := d.rows[service.ID()]
+		if !ok {
+			fmt.Fprintf(w, "PENDING\t%s\t%s\t%s\t-\t-\t-\t\n", service.Name, dashboardTarget(service), service.checkType())
+			rowColors = append(rowColors, color.New(color.FgHiBlack))
+			continue
+		}
+		latency, uptime := "-", "-"
+		if row.result.Status == StatusUp || row.result.Status == StatusWarn {
+			latency = formatLatency(row.result.Latency)
+		}
+		if fraction, checks := state.uptime(service.ID()); checks > 0 {
+			uptime = fmt.Sprintf("%.1f%%", fraction*100)
+		}
+		errorMsg := ""
+		if row.result.Error != nil {
+			errorMsg = truncateChars(strings.Join(strings.Fields(row.result.Error.Error()), " "), dashboardErrorWidth)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", row.result.Status, service.Name, dashboardTarget(service), service.checkType(), latency, uptime, formatChanged(row.changed), errorMsg)
+		rowColors = append(rowColors, dashboardColor(row.result.Status))
+	}
+	w.Flush()
+
+	var screen strings.Builder
+	screen.WriteString(dashboardClear)
+	counts := []string{fmt.Sprintf("%d UP", summary.Statuses[StatusUp])}
+	for _, status := range summaryStatuses[1:] {
+		if summary.Statuses[status] > 0 || status == StatusDown {
+			counts = append(counts, fmt.Sprintf("%d %s", summary.Statuses[status], status))
+		}
+	}
+	screen.WriteString(color.New(color.FgCyan, color.Bold).Sprintf("InfraPulse  %s  %d services  last cycle: %s", time.Now().Format("2006-01-02 15:04:05"), len(services), strings.Join(counts, ", ")))
+	screen.WriteString("\n\n")
+	lines := strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n")
+	screen.WriteString(color.New(color.Bold).Sprint(lines[0]))
+	screen.WriteString("\n")
+	for i, line := range lines[1:] {
+		screen.WriteString(rowColors[i].Sprint(strings.TrimRight(line, " ")))
+		screen.WriteString("\n")
+	}
+	fmt.Fprint(d.out, screen.String())
+}
+
+// dashboardTarget is what a service checks, in a form short enough for a
+// table column.
+func dashboardTarget(service Service) string {
+	switch {
+	case service.HTTP != nil:
+		return service.HTTP.URL
+	case service.DNS != nil:
+		return service.DNS.name(service.Host)
+	case service.MX != nil:
+		return service.MX.domain(service.Host)
+	case service.Exec != nil:
+		return service.Exec.commandLine()
+	case service.Port == 0:
+		return displayHost(service.Host)
+	}
+	// displayHost may append a name, which must follow the brackets of an
+	// IPv6 address rather than end up inside them.
+	address := net.JoinHostPort(service.Host, strconv.Itoa(service.Port))
+	return address + strings.TrimPrefix(displayHost(service.Host), service.Host)
+}
+
+func dashboardColor(status Status) *color.Color {
+	switch status {
+	case StatusUp:
+		return color.New(color.FgGreen)
+	case StatusWarn:
+		return color.New(color.FgYellow)
+	case StatusDown:
+		return color.New(color.FgRed)
+	case StatusUnknown:
+		return color.New(color.FgMagenta)
+	}
+	return color.New(color.FgHiBlack)
+}
+
+// formatChanged renders when a status last changed: the time of day if it
+// was today, the date as well otherwise.
+func formatChanged(t time.Time) string {
+	now := time.Now()
+	if y, m, d := t.Date(); y == now.Year() && m == now.Month() && d == now.Day() {
+		return t.Format("15:04:05")
+	}
+	return t.Format("Jan 2 15:04")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// defaultDedupKeyTemplate identifies a service by its ID: host and port, or
+// the URL for HTTP checks.
+const defaultDedupKeyTemplate = "infrapulse-{{.ID}}"
+
+// dedupKeyData is what a dedup_key_template is rendered with.
+type dedupKeyData struct {
+	ID   string
+	Name string
+	Host string
+	Port int
+	Type string
+}
+
+// assignDedupKeys renders the incident deduplication key for every service.
+// Push integrations use the key to correlate a failure with its recovery, so
+// it must be stable across restarts and unique per service. Duplicates are an
+// error for a custom template; for the default template they only mean the
+// same service is listed twice, which is logged.
+func assignDedupKeys(services []Service, text string) error {
+	custom := text != ""
+	if !custom {
+		text = defaultDedupKeyTemplate
+	}
+	tmpl, err := template.New("dedup_key").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("failed to parse dedup_key_template: %w", err)
+	}
+
+	owners := make(map[string][]string)
+	for i := range services {
+		s := &services[i]
+		var key strings.Builder
+		data := dedupKeyData{ID: s.ID(), Name: s.Name, Host: s.Host, Port: s.Port, Type: s.checkType()}
+		if err := tmpl.Execute(&key, data); err != nil {
+			return fmt.Errorf("failed to render dedup_key_template for %s: %w", s.Name, err)
+		}
+		s.DedupKey = key.String()
+		owners[s.DedupKey] = append(owners[s.DedupKey], fmt.Sprintf("%s (%s)", s.Name, s.ID()))
+	}
+
+	var conflicts []string
+	for key, services := range owners {
+		if len(services) > 1 {
+			conflicts = append(conflicts, fmt.Sprintf("%q is shared by %s", key, strings.Join(services, ", ")))
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	sort.Strings(conflicts)
+	if custom {
+		return fmt.Errorf("dedup_key_template does not produce unique keys: %s", strings.Join(conflicts, "; "))
+	}
+	for _, conflict := range conflicts {
+		slog.Warn("Duplicate service dedup key", "conflict", conflict)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAssignDedupKeys(t *testing.T) {
+	services := []Service{
+		{Name: "db", Host: "db.example.com", Port: 5432},
+		{Name: "db", Host: "db.example.com", Port: 5432, TLS: true},
+		{Name: "web", Host: "example.com", Port: 443, HTTP: &HTTPCheck{URL: "https://example.com/health"}},
+	}
+	tests := []struct {
+		name     string
+		template string
+		want     []string
+		wantErr  bool
+	}{
+		{"default", "", []string{"infrapulse-db.example.com:5432", "infrapulse-db.example.com:5432/tls", "infrapulse-https://example.com/health"}, false},
+		{"custom", "{{.Name}}-{{.Port}}-{{.Type}}", []string{"db-5432-tcp", "db-5432-tls", "web-443-http"}, false},
+		{"duplicate keys", "{{.Host}}-{{.Port}}", nil, true},
+		{"does not parse", "{{.Name", nil, true},
+		{"unknown field", "{{.Team}}", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			services := slices.Clone(services)
+			err := assignDedupKeys(services, tt.template)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("assignDedupKeys succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("assignDedupKeys: %v", err)
+			}
+			var keys []string
+			for _, service := range services {
+				keys = append(keys, service.DedupKey)
+			}
+			if !slices.Equal(keys, tt.want) {
+				t.Errorf("keys = %q, want %q", keys, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssignDedupKeysDefaultDuplicatesOnlyWarn(t *testing.T) {
+	services := []Service{{Name: "a", Host: "db", Port: 5432}, {Name: "b", Host: "db", Port: 5432}}
+	if err := assignDedupKeys(services, ""); err != nil {
+		t.Errorf("assignDedupKeys with the default template = %v, want nil", err)
+	}
+}
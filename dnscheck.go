@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DNSCheck resolves a name and checks that the answer matches an expected
+// record. A service can be up while its name record is broken, which a ping
+// or port check against an IP would never notice.
+type DNSCheck struct {
+	Name     string `yaml:"name"`     // Defaults to the server's host
+	Type     string `yaml:"type"`     // A (default), AAAA, CNAME or MX
+	Expect   string `yaml:"expect"`   // IP, CNAME target or MX host that must be in the answer
+	Resolver string `yaml:"resolver"` // Nameserver to query, host or host:port; system resolver if empty
+}
+
+const dnsDefaultPort = 53
+
+func (c *DNSCheck) recordType() string {
+	if c.Type == "" {
+		return "A"
+	}
+	return strings.ToUpper(c.Type)
+}
+
+// name returns the name to resolve for a server.
+func (c *DNSCheck) name(host string) string {
+	if c.Name == "" {
+		return host
+	}
+	return c.Name
+}
+
+func (c *DNSCheck) resolver() *net.Resolver {
+	if c.Resolver == "" {
+		return net.DefaultResolver
+	}
+	address := c.Resolver
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, fmt.Sprint(dnsDefaultPort))
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		},
+	}
+}
+
+// DNSChecker runs a service's dns check.
+type DNSChecker struct{ Service Service }
+
+// Check resolves the service's name and reports it DOWN if resolution fails,
+// returns no records of the requested type, or lacks the expected one.
+func (c DNSChecker) Check(ctx context.Context) CheckResult {
+	service, timeout := c.Service, c.Service.Timeout
+	check := service.DNS
+	name := check.name(service.Host)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	answers, err := lookupDNS(ctx, check.resolver(), name, check.recordType())
+	latency := time.Since(start)
+	if err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	if len(answers) == 0 {
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("no %s records for %s", check.recordType(), name)}
+	}
+	if check.Expect != "" && !slices.Contains(answers, normalizeDNSAnswer(check.Expect)) {
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("%s %s resolved to %s, want %s", name, check.recordType(), strings.Join(answers, ", "), check.Expect)}
+	}
+	return CheckResult{Service: service, Status: StatusUp, Latency: latency}
+}
+
+// lookupDNS returns the normalized answers for a record type: IPs for A and
+// AAAA, host names for CNAME and MX.
+func lookupDNS(ctx context.Context, resolver *net.Resolver, name, recordType string) ([]string, error) {
+	var answers []string
+	switch recordType {
+	case "A", "AAAA":
+		network := "ip4"
+		if recordType == "AAAA" {
+			network = "ip6"
+		}
+		ips, err := resolver.LookupIP(ctx, network, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			answers = append(answers, ip.String())
+		}
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, normalizeDNSAnswer(cname))
+	case "MX":
+		mxs, err := resolver.LookupMX(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			answers = append(answers, normalizeDNSAnswer(mx.Host))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported record type %q", recordType)
+	}
+	return answers, nil
+}
+
+// normalizeDNSAnswer makes names comparable regardless of case and the
+// trailing root dot, and IPs regardless of notation.
+func normalizeDNSAnswer(s string) string {
+	if ip := net.ParseIP(s); ip != nil {
+		return ip.String()
+	}
+	return strings.ToLower(strings.TrimSuffix(s, "."))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// durationBaselineSamples is how many healthy checks are needed before a
+// service's baseline is trusted for factor-based anomaly detection.
+const durationBaselineSamples = 5
+
+// durationTracker watches how long each check takes to run, independent of
+// its UP/DOWN outcome, and flags checks that are suddenly much slower than
+// usual. A slow-but-successful check is an early sign that the monitoring
+// host or the network path to the target is struggling.
+type durationTracker struct {
+	threshold time.Duration // Absolute limit, 0 to disable
+	factor    float64       // Multiple of the baseline, 0 to disable
+	baselines map[string]*durationBaseline
+}
+
+type durationBaseline struct {
+	avg     time.Duration // Exponentially weighted moving average
+	samples int
+	slow    bool // Whether the last check was anomalous
+}
+
+func newDurationTracker(threshold string, factor float64) (*durationTracker, error) {
+	t := &durationTracker{factor: factor, baselines: make(map[string]*durationBaseline)}
+	if threshold != "" {
+		d, err := time.ParseDuration(threshold)
+		if err != nil {
+			return nil, err
+		}
+		t.threshold = d
+	}
+	if factor < 0 {
+		return nil, fmt.Errorf("check_duration_factor must not be negative, got %g", factor)
+	}
+	return t, nil
+}
+
+// observe records the duration of a successful check and reports whether it
+// has just become anomalous. It only fires on the transition into the slow
+// state so a persistently slow check alerts once rather than every cycle.
+func (t *durationTracker) observe(serviceID string, result CheckResult) (time.Duration, bool) {
+	if result.Status != StatusUp || (t.threshold == 0 && t.factor == 0) {
+		return 0, false
+	}
+
+	b, ok := t.baselines[serviceID]
+	if !ok {
+		b = &durationBaseline{}
+		t.baselines[serviceID] = b
+	}
+
+	anomalous := t.threshold > 0 && result.Duration > t.threshold
+	if t.factor > 0 && b.samples >= durationBaselineSamples && float64(result.Duration) > t.factor*float64(b.avg) {
+		anomalous = true
+	}
+
+	// Keep anomalous samples out of the baseline so a sustained slowdown
+	// doesn't quietly become the new normal.
+	if !anomalous {
+		if b.samples == 0 {
+			b.avg = result.Duration
+		} else {
+			b.avg = (b.avg*4 + result.Duration) / 5
+		}
+		b.samples++
+	}
+
+	fire := anomalous && !b.slow
+	b.slow = anomalous
+	return b.avg, fire
+}
+
+func formatDurationAlert(result CheckResult, baseline time.Duration) string {
+	timestamp := time.Now().Format(time.RFC1123)
+	return fmt.Sprintf("Slow Check Alert\n\nService: %s\nHost: %s\nPort: %d\nTime: %s\nCheck Duration: %s\nBaseline: %s\nDetails: The check succeeded but took much longer than usual. The monitoring host or network path may be degraded.\n", result.Service.Name, displayHost(result.Service.Host), result.Service.Port, timestamp, result.Duration.Round(time.Millisecond), baseline.Round(time.Millisecond))
+}
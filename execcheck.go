@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ExecCheck runs an external command, Nagios-plugin style, for health checks
+// no built-in type covers: exit code 0 is UP, anything else is DOWN.
+type ExecCheck struct {
+	Command string   `yaml:"command"` // Program to run, looked up in PATH if it has no slash
+	Args    []string `yaml:"args"`
+}
+
+// execWaitDelay is how long a check waits for the command's output to close
+// after the command exits or is killed. A background process the command left
+// holding stdout or stderr would otherwise keep the check from returning.
+const execWaitDelay = time.Second
+
+// execErrorLimit caps how much of a failing command's output goes into the
+// result's error, and from there into alerts.
+const execErrorLimit = 512
+
+// commandLine renders the command and its arguments for display.
+func (c *ExecCheck) commandLine() string {
+	return strings.Join(append([]string{c.Command}, c.Args...), " ")
+}
+
+// ExecChecker runs a server's exec check.
+type ExecChecker struct{ Service Service }
+
+// Check runs the command with the service's timeout, killing it if it runs
+// longer. The command gets the server's name and host in INFRAPULSE_NAME and
+// INFRAPULSE_HOST. When it fails, its stderr, or its stdout if stderr is
+// empty, becomes the result's error.
+func (c ExecChecker) Check(ctx context.Context) CheckResult {
+	service, timeout := c.Service, c.Service.Timeout
+	check := service.Exec
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, check.Command, check.Args...)
+	cmd.Env = append(os.Environ(), "INFRAPULSE_NAME="+service.Name, "INFRAPULSE_HOST="+service.Host)
+	cmd.WaitDelay = execWaitDelay
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	latency := time.Since(start)
+	if err == nil {
+		return CheckResult{Service: service, Status: StatusUp, Latency: latency}
+	}
+	if ctx.Err() != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("%s did not finish within %s", filepath.Base(check.Command), timeout)}
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	output := strings.TrimSpace(stderr.String())
+	if output == "" {
+		output = strings.TrimSpace(stdout.String())
+	}
+	if output == "" {
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("%s: %s", err, truncateChars(output, execErrorLimit))}
+}
@@ -0,0 +1,67 @@
+package main
+
+// GroupConfig routes the alerts of the servers in a group to the group's own
+// email recipients and, optionally, its own chat, paging and webhook
+// channels. A channel the group does not set falls back to the global one.
+type GroupConfig struct {
+	AlertRecipient string           `yaml:"alert_recipient"`
+	Slack          *SlackConfig     `yaml:"slack"`
+	Discord        *DiscordConfig   `yaml:"discord"`
+	Telegram       *TelegramConfig  `yaml:"telegram"`
+	PagerDuty      *PagerDutyConfig `yaml:"pagerduty"`
+	Webhook        *WebhookConfig   `yaml:"webhook"`
+}
+
+// forGroup returns the configuration alerts for the named group are sent
+// with: cfg itself for ungrouped services, otherwise a copy with the group's
+// recipients and channels in place of the global ones.
+func (cfg *Config) forGroup(name string) *Config {
+	group, ok := cfg.Groups[name]
+	if name == "" || !ok {
+		return cfg
+	}
+	grouped := *cfg
+	if group.AlertRecipient != "" {
+		grouped.AlertRecipient = group.AlertRecipient
+	}
+	if group.Slack != nil {
+		grouped.Slack = *group.Slack
+	}
+	if group.Discord != nil {
+		grouped.Discord = *group.Discord
+	}
+	if group.Telegram != nil {
+		// A group usually posts to its own chat through the same bot.
+		global := grouped.Telegram
+		grouped.Telegram = *group.Telegram
+		if grouped.Telegram.BotToken == "" {
+			grouped.Telegram.BotToken = global.BotToken
+		}
+		if grouped.Telegram.APIURL == "" {
+			grouped.Telegram.APIURL = global.APIURL
+		}
+	}
+	if group.PagerDuty != nil {
+		grouped.PagerDuty = *group.PagerDuty
+	}
+	if group.Webhook != nil {
+		grouped.Webhook = *group.Webhook
+	}
+	return &grouped
+}
+
+// groupAlerts buckets alerts by their service's group, keeping the order in
+// which groups first appear. Alerts without a service, such as
+// self-monitoring, are ungrouped.
+func groupAlerts(alerts []Alert) ([]string, map[string][]Alert) {
+	var order []string
+	byGroup := make(map[string][]Alert)
+	for _, alert := range alerts {
+		group := alert.Result.Service.Group
+		if _, ok := byGroup[group]; !ok {
+			order = append(order, group)
+		}
+		byGroup[group] = append(byGroup[group], alert)
+	}
+	return order, byGroup
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// GRPCReflectionCheck asserts that a gRPC server lists a service, and
+// optionally one of its methods, through server reflection. This confirms the
+// server is running the expected build, not just that it is healthy.
+type GRPCReflectionCheck struct {
+	Service string `yaml:"service"` // Fully-qualified, e.g. "orders.v1.OrderService"
+	Method  string `yaml:"method"`  // Optional method name on Service
+}
+
+// dialGRPC opens a client connection, using TLS when the port is flagged tls.
+func dialGRPC(service Service) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if service.TLS {
+		creds = credentials.NewTLS(&tls.Config{ServerName: service.Host, InsecureSkipVerify: service.InsecureSkipVerify})
+	}
+	address := net.JoinHostPort(service.Host, strconv.Itoa(service.Port))
+	dialer := service.dialer("tcp", 0)
+	return grpc.NewClient(address, grpc.WithTransportCredentials(creds), grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "tcp", address)
+	}))
+}
+
+// GRPCHealthChecker runs a port's grpc health check.
+type GRPCHealthChecker struct{ Service Service }
+
+// Check calls Check from the standard gRPC health checking protocol and
+// reports the port DOWN unless the server answers SERVING for the configured
+// service, or for the server as a whole when none is set.
+func (c GRPCHealthChecker) Check(ctx context.Context) CheckResult {
+	service, timeout := c.Service, c.Service.Timeout
+	conn, err := dialGRPC(service)
+	if err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service.GRPCService})
+	latency := time.Since(start)
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Unimplemented:
+			err = fmt.Errorf("health checking is not enabled: %w", err)
+		case codes.NotFound:
+			err = fmt.Errorf("health of service %q is unknown to the server: %w", service.GRPCService, err)
+		}
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("health status is %s", resp.GetStatus())}
+	}
+	return CheckResult{Service: service, Status: StatusUp, Latency: latency}
+}
+
+// GRPCReflectionChecker runs a port's grpc_reflection check.
+type GRPCReflectionChecker struct{ Service Service }
+
+func (c GRPCReflectionChecker) Check(ctx context.Context) CheckResult {
+	service, timeout := c.Service, c.Service.Timeout
+	check := service.GRPCReflection
+
+	conn, err := dialGRPC(service)
+	if err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	defer stream.CloseSend()
+
+	resp, err := reflectionRequest(stream, &reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			err = fmt.Errorf("server reflection is not enabled: %w", err)
+		}
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+
+	var available []string
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		available = append(available, s.GetName())
+	}
+	if !slices.Contains(available, check.Service) {
+		slog.Debug("gRPC reflection service mismatch", "host", service.Host, "port", service.Port, "want", check.Service, "available", available)
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("service %s is not listed by reflection", check.Service)}
+	}
+	if check.Method == "" {
+		return CheckResult{Service: service, Status: StatusUp}
+	}
+
+	methods, err := reflectionMethods(stream, check.Service)
+	if err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	if !slices.Contains(methods, check.Method) {
+		slog.Debug("gRPC reflection method mismatch", "host", service.Host, "port", service.Port, "service", check.Service, "want", check.Method, "available", methods)
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("method %s/%s is not listed by reflection", check.Service, check.Method)}
+	}
+	return CheckResult{Service: service, Status: StatusUp}
+}
+
+func reflectionRequest(stream reflectionpb.ServerReflection_ServerReflectionInfoClient, req *reflectionpb.ServerReflectionRequest) (*reflectionpb.ServerReflectionResponse, error) {
+	if err := stream.Send(req); err != nil {
+		return nil, err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if e := resp.GetErrorResponse(); e != nil {
+		return nil, fmt.Errorf("reflection error: %s", e.GetErrorMessage())
+	}
+	return resp, nil
+}
+
+// reflectionMethods lists the method names of a fully-qualified service.
+func reflectionMethods(stream reflectionpb.ServerReflection_ServerReflectionInfoClient, serviceName string) ([]string, error) {
+	resp, err := reflectionRequest(stream, &reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: serviceName},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+		var file descriptorpb.FileDescriptorProto
+		if err := proto.Unmarshal(raw, &file); err != nil {
+			return nil, fmt.Errorf("invalid file descriptor: %w", err)
+		}
+		for _, svc := range file.GetService() {
+			name := svc.GetName()
+			if file.GetPackage() != "" {
+				name = file.GetPackage() + "." + name
+			}
+			if name != serviceName {
+				continue
+			}
+			var methods []string
+			for _, m := range svc.GetMethod() {
+				methods = append(methods, m.GetName())
+			}
+			return methods, nil
+		}
+	}
+	return nil, fmt.Errorf("no descriptor found for service %s", serviceName)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// defaultHistorySize is how many recent results are kept per service.
+const defaultHistorySize = 1440
+
+// historyPoint is a single recorded check result.
+type historyPoint struct {
+	Time     time.Time
+	Status   Status
+	Duration time.Duration
+	Latency  time.Duration
+}
+
+// historyStore keeps a bounded, in-memory history of check results per
+// service. It is written by the monitoring loop and read concurrently by the
+// HTTP API.
+type historyStore struct {
+	mu     sync.RWMutex
+	size   int
+	points map[string][]historyPoint
+}
+
+func newHistoryStore(size int) *historyStore {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	return &historyStore{size: size, points: make(map[string][]historyPoint)}
+}
+
+func (h *historyStore) record(serviceID string, result CheckResult, at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	points := append(h.points[serviceID], historyPoint{Time: at, Status: result.Status, Duration: result.Duration, Latency: result.Latency})
+	if len(points) > h.size {
+		points = points[len(points)-h.size:]
+	}
+	h.points[serviceID] = points
+}
+
+// serviceIDs lists every service that has recorded history.
+func (h *historyStore) serviceIDs() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	ids := make([]string, 0, len(h.points))
+	for id := range h.points {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// between returns a copy of a service's points recorded within [from, to].
+func (h *historyStore) between(serviceID string, from, to time.Time) []historyPoint {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var points []historyPoint
+	for _, p := range h.points[serviceID] {
+		if !p.Time.Before(from) && !p.Time.After(to) {
+			points = append(points, p)
+		}
+	}
+	return points
+}
+
+// retain drops the history of every service whose ID is not in ids.
+func (h *historyStore) retain(ids map[string]bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id := range h.points {
+		if !ids[id] {
+			delete(h.points, id)
+		}
+	}
+}
+
+// resize changes how many points are kept per service, dropping the oldest
+// ones if the history shrinks. A size that is not positive means
+// defaultHistorySize.
+func (h *historyStore) resize(size int) {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.size = size
+	for id, points := range h.points {
+		if len(points) > size {
+			h.points[id] = slices.Clone(points[len(points)-size:])
+		}
+	}
+}
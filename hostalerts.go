@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// hostKey identifies the host of a server entry. Servers sharing an address
+// are kept apart, since they may be routed to different alert groups.
+type hostKey struct{ name, host string }
+
+func hostKeyOf(service Service) hostKey {
+	return hostKey{service.Name, service.Host}
+}
+
+// collapseHostAlerts replaces the down alerts of a host whose every check in
+// results failed with a single host-down alert listing the affected services,
+// so an unreachable host doesn't produce one near-identical alert per port.
+// Hosts with only some checks down keep their individual alerts. Ports
+// expected to be closed say nothing about whether the host is up, so they are
+// left out of both.
+func collapseHostAlerts(alerts []Alert, results []CheckResult) []Alert {
+	hostDown := make(map[hostKey]bool)
+	for _, result := range results {
+		if result.Service.ExpectClosed {
+			continue
+		}
+		key := hostKeyOf(result.Service)
+		down, seen := hostDown[key]
+		hostDown[key] = result.Status == StatusDown && (down || !seen)
+	}
+	byHost := make(map[hostKey][]CheckResult)
+	for _, alert := range alerts {
+		if key := hostKeyOf(alert.Result.Service); alert.Kind == "down" && !alert.Result.Service.ExpectClosed && hostDown[key] {
+			byHost[key] = append(byHost[key], alert.Result)
+		}
+	}
+
+	collapsed := make([]Alert, 0, len(alerts))
+	for _, alert := range alerts {
+		affected := byHost[hostKeyOf(alert.Result.Service)]
+		if alert.Kind != "down" || alert.Result.Service.ExpectClosed || len(affected) < 2 {
+			collapsed = append(collapsed, alert)
+			continue
+		}
+		// The first alert for the host stands in for all of them.
+		if alert.Result.Service == affected[0].Service {
+			hostAlert := newAlert("down", alert.Result, formatHostAlert(affected))
+			hostAlert.Affected = affected
+			collapsed = append(collapsed, hostAlert)
+		}
+	}
+	return collapsed
+}
+
+// formatHostAlert describes a host with every check down, listing each
+// affected service with its error and incident ID.
+func formatHostAlert(affected []CheckResult) string {
+	service := affected[0].Service
+	var b strings.Builder
+	fmt.Fprintf(&b, "Host Down Alert\n\nHost %s (%s) is down (%d services affected)\nTime: %s\n\nAffected services:\n", service.Name, displayHost(service.Host), len(affected), time.Now().Format(time.RFC1123))
+	for _, result := range affected {
+		errorMsg := "No specific error message."
+		if result.Error != nil {
+			errorMsg = result.Error.Error()
+		}
+		fmt.Fprintf(&b, "- %s (%s): %s\n  Incident: %s\n", result.Service.ID(), result.Service.checkType(), errorMsg, result.Service.DedupKey)
+	}
+	return b.String()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCollapseHostAlerts(t *testing.T) {
+	type check struct {
+		name   string
+		port   int
+		status Status
+		closed bool // Expected to be closed
+		alert  string
+	}
+	tests := []struct {
+		name   string
+		checks []check
+		want   []string // Kind and target of each alert, with the affected ports of a host alert
+	}{
+		{
+			name:   "every check down",
+			checks: []check{{"db", 22, StatusDown, false, "down"}, {"db", 5432, StatusDown, false, "down"}},
+			want:   []string{"down db [22 5432]"},
+		},
+		{
+			name:   "some checks up",
+			checks: []check{{"db", 22, StatusUp, false, ""}, {"db", 5432, StatusDown, false, "down"}, {"db", 6432, StatusDown, false, "down"}},
+			want:   []string{"down db:5432", "down db:6432"},
+		},
+		{
+			name:   "single check",
+			checks: []check{{"db", 5432, StatusDown, false, "down"}},
+			want:   []string{"down db:5432"},
+		},
+		{
+			name:   "closed port left out",
+			checks: []check{{"db", 22, StatusDown, false, "down"}, {"db", 5432, StatusDown, false, "down"}, {"db", 23, StatusUp, true, ""}, {"db", 25, StatusDown, true, "down"}},
+			want:   []string{"down db [22 5432]", "down db:25"},
+		},
+		{
+			name:   "recoveries kept",
+			checks: []check{{"db", 22, StatusDown, false, "down"}, {"db", 5432, StatusDown, false, "down"}, {"web", 443, StatusUp, false, "recovery"}},
+			want:   []string{"down db [22 5432]", "recovery web:443"},
+		},
+		{
+			name:   "servers sharing a host kept apart",
+			checks: []check{{"db", 22, StatusDown, false, "down"}, {"db", 5432, StatusDown, false, "down"}, {"db-replica", 6432, StatusDown, false, "down"}},
+			want:   []string{"down db [22 5432]", "down db-replica:6432"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []CheckResult
+			var alerts []Alert
+			for _, c := range tt.checks {
+				result := CheckResult{Service: Service{Name: c.name, Host: "10.0.0.1", Port: c.port, ExpectClosed: c.closed}, Status: c.status}
+				results = append(results, result)
+				if c.alert != "" {
+					alerts = append(alerts, Alert{Kind: c.alert, Result: result})
+				}
+			}
+			var got []string
+			for _, alert := range collapseHostAlerts(alerts, results) {
+				if len(alert.Affected) == 0 {
+					got = append(got, fmt.Sprintf("%s %s:%d", alert.Kind, alert.Result.Service.Name, alert.Result.Service.Port))
+					continue
+				}
+				var ports []string
+				for _, result := range alert.Affected {
+					ports = append(ports, fmt.Sprint(result.Service.Port))
+				}
+				got = append(got, fmt.Sprintf("%s %s [%s]", alert.Kind, alert.Result.Service.Name, strings.Join(ports, " ")))
+				if !strings.Contains(alert.Message, fmt.Sprintf("(%d services affected)", len(alert.Affected))) {
+					t.Errorf("host alert message doesn't count the affected services:\n%s", alert.Message)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("collapseHostAlerts = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
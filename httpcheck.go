@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HTTPCheck requests a URL and asserts on the response.
+type HTTPCheck struct {
+	URL             string            `yaml:"url"`
+	ExpectedStatus  StatusRange       `yaml:"expected_status"` // Default any status below 400
+	BodyContains    string            `yaml:"body_contains"`   // Substring required in the first 64KB of the body
+	RequiredHeaders []HeaderAssertion `yaml:"required_headers"`
+
+	// Credentials and extra request headers for endpoints that need them.
+	BasicAuth       *HTTPBasicAuth    `yaml:"basic_auth"`
+	BearerToken     string            `yaml:"bearer_token"`
+	BearerTokenEnv  string            `yaml:"bearer_token_env"`
+	BearerTokenFile string            `yaml:"bearer_token_file"`
+	Headers         map[string]string `yaml:"headers"` // e.g. an API key header
+}
+
+// HTTPBasicAuth is the username and password sent with an HTTP check.
+type HTTPBasicAuth struct {
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordEnv  string `yaml:"password_env"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+// StatusRange is an accepted HTTP status: a single code such as 200 or an
+// inclusive range such as "200-399".
+type StatusRange struct {
+	Min, Max int
+}
+
+func (r *StatusRange) UnmarshalYAML(node *yaml.Node) error {
+	var text string
+	if err := node.Decode(&text); err != nil {
+		return err
+	}
+	from, to, isRange := strings.Cut(text, "-")
+	min, err := strconv.Atoi(strings.TrimSpace(from))
+	max := min
+	if err == nil && isRange {
+		max, err = strconv.Atoi(strings.TrimSpace(to))
+	}
+	if err != nil || min < 100 || max > 599 || min > max {
+		return fmt.Errorf("line %d: invalid expected_status %q, want a code or a range like 200-399", node.Line, text)
+	}
+	r.Min, r.Max = min, max
+	return nil
+}
+
+// accepts reports whether code is in the range, or below 400 when unset.
+func (r StatusRange) accepts(code int) bool {
+	if r.Min == 0 {
+		return code < 400
+	}
+	return code >= r.Min && code <= r.Max
+}
+
+func (r StatusRange) String() string {
+	switch {
+	case r.Min == 0:
+		return "below 400"
+	case r.Min == r.Max:
+		return strconv.Itoa(r.Min)
+	default:
+		return fmt.Sprintf("%d-%d", r.Min, r.Max)
+	}
+}
+
+// port returns the TCP port the URL points at, used for display and dedup keys.
+func (c *HTTPCheck) port() int {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return 0
+	}
+	if p := u.Port(); p != "" {
+		port, _ := strconv.Atoi(p)
+		return port
+	}
+	if u.Scheme == "https" {
+		return 443
+	}
+	return 80
+}
+
+// HeaderAssertion requires a response header to be present and, optionally,
+// to equal a value or match a regular expression.
+type HeaderAssertion struct {
+	Name    string `yaml:"name"`
+	Value   string `yaml:"value"`   // Exact value, optional
+	Pattern string `yaml:"pattern"` // Regular expression, optional
+	Warn    bool   `yaml:"warn"`    // Report WARN instead of DOWN on failure
+
+	pattern *regexp.Regexp
+}
+
+func (h *HeaderAssertion) UnmarshalYAML(node *yaml.Node) error {
+	type plain HeaderAssertion
+	if err := node.Decode((*plain)(h)); err != nil {
+		return err
+	}
+	if h.Name == "" {
+		return fmt.Errorf("line %d: required header is missing a name", node.Line)
+	}
+	if h.Pattern != "" {
+		pattern, err := regexp.Compile(h.Pattern)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid pattern for header %s: %w", node.Line, h.Name, err)
+		}
+		h.pattern = pattern
+	}
+	return nil
+}
+
+// check reports why the header assertion fails, or nil if it holds.
+func (h *HeaderAssertion) check(header http.Header) error {
+	values, ok := header[http.CanonicalHeaderKey(h.Name)]
+	if !ok {
+		return fmt.Errorf("missing header %s", http.CanonicalHeaderKey(h.Name))
+	}
+	value := strings.Join(values, ", ")
+	if h.Value != "" && value != h.Value {
+		return fmt.Errorf("header %s is %q, want %q", h.Name, value, h.Value)
+	}
+	if h.pattern != nil && !h.pattern.MatchString(value) {
+		return fmt.Errorf("header %s value %q does not match %s", h.Name, value, h.Pattern)
+	}
+	return nil
+}
+
+// httpBodyLimit caps how much of a response body is read.
+const httpBodyLimit = 64 << 10
+
+// HTTPChecker runs one of a server's http_checks.
+type HTTPChecker struct{ Service Service }
+
+func (c HTTPChecker) Check(ctx context.Context) CheckResult {
+	service, timeout := c.Service, c.Service.Timeout
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, service.HTTP.URL, nil)
+	if err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	for name, value := range service.HTTP.Headers {
+		req.Header.Set(name, value)
+	}
+	if auth := service.HTTP.BasicAuth; auth != nil {
+		req.SetBasicAuth(auth.Username, auth.Password)
+	}
+	if service.HTTP.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+service.HTTP.BearerToken)
+	}
+	client := &http.Client{Timeout: timeout}
+	if service.SourceAddress != "" {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = service.dialer("tcp", timeout).DialContext
+		client.Transport = transport
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, httpBodyLimit))
+	if err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("status %d, failed to read body: %w", resp.StatusCode, err)}
+	}
+
+	if !service.HTTP.ExpectedStatus.accepts(resp.StatusCode) {
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("status %d, want %s", resp.StatusCode, service.HTTP.ExpectedStatus)}
+	}
+	if service.HTTP.BodyContains != "" && !strings.Contains(string(body), service.HTTP.BodyContains) {
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("status %d, body does not contain %q", resp.StatusCode, service.HTTP.BodyContains)}
+	}
+
+	var failures, warnings []string
+	for _, assertion := range service.HTTP.RequiredHeaders {
+		if err := assertion.check(resp.Header); err != nil {
+			if assertion.Warn {
+				warnings = append(warnings, err.Error())
+			} else {
+				failures = append(failures, err.Error())
+			}
+		}
+	}
+	if len(failures) > 0 {
+		return CheckResult{Service: service, Status: StatusDown, Error: errors.New(strings.Join(append(failures, warnings...), "; "))}
+	}
+	if len(warnings) > 0 {
+		return CheckResult{Service: service, Status: StatusWarn, Error: errors.New(strings.Join(warnings, "; "))}
+	}
+	return CheckResult{Service: service, Status: StatusUp}
+}
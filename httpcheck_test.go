@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestStatusRange(t *testing.T) {
+	tests := []struct {
+		yaml     string
+		want     StatusRange
+		wantErr  bool
+		accepts  []int
+		rejects  []int
+		wantText string
+	}{
+		{yaml: "200", want: StatusRange{200, 200}, accepts: []int{200}, rejects: []int{201, 301}, wantText: "200"},
+		{yaml: `"200-399"`, want: StatusRange{200, 399}, accepts: []int{200, 302, 399}, rejects: []int{199, 400}, wantText: "200-399"},
+		{yaml: `" 401 - 403 "`, want: StatusRange{401, 403}, accepts: []int{401, 403}, rejects: []int{400, 404}, wantText: "401-403"},
+		{yaml: "99", wantErr: true},
+		{yaml: "600", wantErr: true},
+		{yaml: `"400-200"`, wantErr: true},
+		{yaml: `"2xx"`, wantErr: true},
+		{yaml: `"200-"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.yaml, func(t *testing.T) {
+			var r StatusRange
+			err := yaml.Unmarshal([]byte(tt.yaml), &r)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Unmarshal accepted %s as %v", tt.yaml, r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if r != tt.want {
+				t.Errorf("got %v, want %v", r, tt.want)
+			}
+			for _, code := range tt.accepts {
+				if !r.accepts(code) {
+					t.Errorf("%v does not accept %d", r, code)
+				}
+			}
+			for _, code := range tt.rejects {
+				if r.accepts(code) {
+					t.Errorf("%v accepts %d", r, code)
+				}
+			}
+			if got := r.String(); got != tt.wantText {
+				t.Errorf("String() = %q, want %q", got, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestStatusRangeUnset(t *testing.T) {
+	var r StatusRange
+	if !r.accepts(399) || r.accepts(400) {
+		t.Error("unset range should accept exactly the codes below 400")
+	}
+	if got := r.String(); got != "below 400" {
+		t.Errorf("String() = %q, want %q", got, "below 400")
+	}
+}
+
+func TestHeaderAssertion(t *testing.T) {
+	header := http.Header{
+		"Content-Type":  {"application/json"},
+		"Cache-Control": {"no-cache", "no-store"},
+	}
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string // Substring of the failure, empty if the assertion holds
+	}{
+		{"present", "name: content-type", ""},
+		{"missing", "name: X-Request-Id", "missing header X-Request-Id"},
+		{"exact value", "{name: Content-Type, value: application/json}", ""},
+		{"wrong value", "{name: Content-Type, value: text/html}", `is "application/json", want "text/html"`},
+		{"repeated header joined", `{name: Cache-Control, value: "no-cache, no-store"}`, ""},
+		{"pattern", `{name: Content-Type, pattern: "^application/"}`, ""},
+		{"pattern mismatch", `{name: Content-Type, pattern: "^text/"}`, "does not match ^text/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h HeaderAssertion
+			if err := yaml.Unmarshal([]byte(tt.yaml), &h); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			err := h.check(header)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("check() = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("check() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHeaderAssertionInvalid(t *testing.T) {
+	for _, text := range []string{"value: x", `{name: X, pattern: "("}`} {
+		var h HeaderAssertion
+		if err := yaml.Unmarshal([]byte(text), &h); err == nil {
+			t.Errorf("Unmarshal accepted %s", text)
+		}
+	}
+}
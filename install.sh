@@ -105,7 +105,10 @@ print_success "Go modules tidied."
 
 # 3. Build the binary
 print_info "Building the 'infrapulse' binary..."
-go build -o infrapulse main.go
+VERSION=$(git describe --tags --always 2>/dev/null || echo dev)
+COMMIT=$(git rev-parse --short HEAD 2>/dev/null || echo unknown)
+BUILD_DATE=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+go build -ldflags "-X main.version=$VERSION -X main.commit=$COMMIT -X main.buildDate=$BUILD_DATE" -o infrapulse .
 print_success "Binary built successfully."
 
 # 4. Create configuration directory
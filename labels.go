@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// labelSet is a service's labels, such as env=prod, in a comparable form so
+// Service can still be used as a map key: key=value pairs sorted by key and
+// separated by NUL bytes. Keys are already sanitized for Prometheus.
+type labelSet string
+
+// label is one key and value of a labelSet.
+type label struct {
+	Key, Value string
+}
+
+// reservedLabels are the labels InfraPulse's own metrics already carry, and
+// le, which Prometheus reserves for histogram buckets such as the latency
+// histogram's.
+var reservedLabels = append(slices.Clone(serviceMetricLabels), "le")
+
+// sanitizeLabelKey turns a label key into a valid Prometheus label name:
+// characters other than letters, digits and underscores become underscores,
+// and a leading digit is prefixed with one.
+func sanitizeLabelKey(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// newLabelSet builds a labelSet from a server's labels. Keys that sanitize to
+// the same name are rejected by validateLabels; here the last one wins.
+func newLabelSet(labels map[string]string) labelSet {
+	sanitized := make(map[string]string, len(labels))
+	for key, value := range labels {
+		sanitized[sanitizeLabelKey(key)] = value
+	}
+	pairs := make([]string, 0, len(sanitized))
+	for _, key := range slices.Sorted(maps.Keys(sanitized)) {
+		pairs = append(pairs, key+"="+sanitized[key])
+	}
+	return labelSet(strings.Join(pairs, "\x00"))
+}
+
+func (l labelSet) labels() []label {
+	if l == "" {
+		return nil
+	}
+	var labels []label
+	for _, pair := range strings.Split(string(l), "\x00") {
+		key, value, _ := strings.Cut(pair, "=")
+		labels = append(labels, label{key, value})
+	}
+	return labels
+}
+
+// toMap returns the labels as a map, or nil if there are none.
+func (l labelSet) toMap() map[string]string {
+	if l == "" {
+		return nil
+	}
+	m := make(map[string]string)
+	for _, label := range l.labels() {
+		m[label.Key] = label.Value
+	}
+	return m
+}
+
+// String renders the labels for alert messages, e.g. "env=prod, team=payments".
+func (l labelSet) String() string {
+	return strings.ReplaceAll(string(l), "\x00", ", ")
+}
+
+// validateLabels reports what is wrong with a server's labels, if anything.
+func validateLabels(labels map[string]string) []string {
+	var problems []string
+	seen := make(map[string]string)
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		name := sanitizeLabelKey(key)
+		switch {
+		case key == "":
+			problems = append(problems, "label keys must not be empty")
+		case slices.Contains(reservedLabels, name):
+			problems = append(problems, fmt.Sprintf("label %q is reserved for InfraPulse's own metrics", key))
+		case strings.HasPrefix(name, "__"):
+			problems = append(problems, fmt.Sprintf("label %q must not start with __, which Prometheus reserves", key))
+		case seen[name] != "":
+			problems = append(problems, fmt.Sprintf("labels %q and %q are both %q once sanitized for Prometheus", seen[name], key, name))
+		}
+		seen[name] = key
+	}
+	return problems
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+// printServiceList prints the services the configuration expands to, one per
+// line in config order, for -list.
+func printServiceList(services []Service) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tHOST\tPORT\tCHECK\tID")
+	for _, service := range services {
+		port := "-"
+		if service.Port != 0 {
+			port = strconv.Itoa(service.Port)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", service.Name, service.Host, port, service.checkType(), service.ID())
+	}
+	w.Flush()
+	fmt.Printf("%d services\n", len(services))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// logToFile is set by -log-file: slog output and a structured record of
+// every check go to a rotating file instead of the console.
+var logToFile bool
+
+// rotatingLog is an io.Writer that appends to a file, rotating it at 10MB and
+// keeping 3 backups, the same way the file alert channel does.
+type rotatingLog struct {
+	mu  sync.Mutex
+	cfg FileNotifierConfig
+}
+
+func (l *rotatingLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := appendLine(l.cfg, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// setupLogging installs the slog handler set by -log-format and -log-level,
+// writing to stderr.
+func setupLogging(format, level string) error {
+	opts, err := logOptions(level)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(format) {
+	case "", "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, opts)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, opts)))
+	default:
+		return fmt.Errorf("unknown log format %q, want text or json", format)
+	}
+	return nil
+}
+
+// logOptions maps a -log-level value (debug, info, warn or error) to handler
+// options.
+func logOptions(level string) (*slog.HandlerOptions, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q, want debug, info, warn or error", level)
+	}
+	return &slog.HandlerOptions{Level: l}, nil
+}
+
+// setupLogFile sends slog output to path as JSON and silences the colored
+// console output, which is meant for interactive use.
+func setupLogFile(path, level string) error {
+	opts, err := logOptions(level)
+	if err != nil {
+		return err
+	}
+	w := &rotatingLog{cfg: FileNotifierConfig{Path: path, MaxSizeMB: 10, MaxBackups: 3}}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(w, opts)))
+	color.Output = io.Discard
+	logToFile = true
+	return nil
+}
+
+// logResult writes the structured record of a check to the log file.
+func logResult(result CheckResult) {
+	attrs := []any{
+		"name", result.Service.Name,
+		"host", result.Service.Host,
+		"port", result.Service.Port,
+		"check", result.Service.checkType(),
+		"status", result.Status,
+		"latency_ms", milliseconds(result.Latency),
+		"duration_ms", milliseconds(result.Duration),
+	}
+	if result.Error != nil {
+		attrs = append(attrs, "error", result.Error.Error())
+	}
+	slog.Info("Check result", attrs...)
+}
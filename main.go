@@ -1,45 +1,218 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
 	"net"
-	"net/smtp"
+	"net/http/httptrace"
 	"os"
 	"os/signal"
 	"path/filepath"
-	
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/fatih/color"
-	"github.com/prometheus-community/pro-bing"
 	"gopkg.in/yaml.v3"
 )
 
 // --- Structs for Configuration ---
 
 type Server struct {
-	Name  string   `yaml:"name"`
-	Host  string   `yaml:"host"`
-	Ports []int    `yaml:"ports"`
+	Name  string     `yaml:"name"`
+	Host  string     `yaml:"host"`
+	Ports []PortSpec `yaml:"ports"`
+	SYN   bool       `yaml:"syn"` // Half-open SYN probe instead of a full TCP connect
+
+	InsecureSkipVerify bool       `yaml:"insecure_skip_verify"` // Accept self-signed certificates on TLS ports
+	STUN               *STUNCheck `yaml:"stun"`
+	DNS                *DNSCheck  `yaml:"dns"`
+	SNMP               *SNMPCheck `yaml:"snmp"`
+	MX                 *MXCheck   `yaml:"mx"`
+	Exec               *ExecCheck `yaml:"exec"`
+
+	HTTPChecks []HTTPCheck `yaml:"http_checks"`
+
+	PingSuccessPolicy PingPolicy  `yaml:"ping_success_policy"` // Overrides the global policy
+	PingCount         int         `yaml:"ping_count"`          // Overrides the global packet count
+	PingInterval      string      `yaml:"ping_interval"`       // Overrides the global packet interval
+	PingPrivileged    *bool       `yaml:"ping_privileged"`     // Overrides the global socket mode
+	ActiveWindow      *TimeWindow `yaml:"active_window"`       // Failures only count inside this daily window
+
+	Interval string `yaml:"interval"` // Overrides check_interval in monitoring loop mode
+	Timeout  string `yaml:"timeout"`  // Overrides the global check timeout
+
+	LatencyThreshold string `yaml:"latency_threshold"` // Overrides the global latency threshold
+	SourceAddress    string `yaml:"source_address"`    // Overrides the global source address
+
+	Stage    int  `yaml:"stage"`    // Checked after all lower stages
+	Critical bool `yaml:"critical"` // A DOWN check skips all later stages
+
+	Group  string            `yaml:"group"`  // Routes alerts to the group's recipients in config.yaml
+	Labels map[string]string `yaml:"labels"` // Metadata such as env: prod, shown in alerts and metrics
+
+	MaintenanceWindows []MaintenanceWindow `yaml:"maintenance_windows"` // Hold back this server's alerts
 }
 
-type SMTPConfig struct {
-	Host     string `yaml:"host"`
+// PortSpec is an entry in a server's port list: a bare port number, a range
+// string like "8000-8020", or a mapping with per-port options.
+type PortSpec struct {
 	Port     int    `yaml:"port"`
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
+	EndPort  int    `yaml:"-"`         // Last port of a range, 0 for a single port
+	Protocol string `yaml:"protocol"`  // "tcp" (default) or "udp"
+	State    string `yaml:"state"`     // "open" (default), or "closed" to alert when the port accepts connections
+	Probe    string `yaml:"probe"`     // UDP payload whose reply proves the service is up
+	ProbeHex string `yaml:"probe_hex"` // Same as probe, hex-encoded for binary protocols
+	TLS      bool   `yaml:"tls"`       // Complete a TLS handshake
+	OCSP     bool   `yaml:"ocsp"`      // Check the served certificate for revocation (requires tls)
+
+	CertWarnDays int `yaml:"cert_warn_days"` // Fail when the certificate expires sooner, default 14
+
+	GRPC        bool   `yaml:"grpc"`         // Call the gRPC health checking protocol's Check
+	GRPCService string `yaml:"grpc_service"` // Service name to ask about, the server as a whole if empty
+
+	Send   string `yaml:"send"`   // Written after connecting, before reading the reply
+	Expect string `yaml:"expect"` // Substring the reply must contain, shorthand for a one-step tcp_script
+
+	GRPCReflection *GRPCReflectionCheck `yaml:"grpc_reflection"`
+	TCPScript      *TCPScriptCheck      `yaml:"tcp_script"`
+	TCPProbe       *TCPProbeCheck       `yaml:"tcp_probe"`
+
+	ActiveWindow *TimeWindow `yaml:"active_window"` // Overrides the server's window
+}
+
+func (p *PortSpec) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		start, end, isRange := strings.Cut(node.Value, "-")
+		if !isRange {
+			return node.Decode(&p.Port)
+		}
+		var err error
+		if p.Port, err = strconv.Atoi(strings.TrimSpace(start)); err != nil {
+			return fmt.Errorf("line %d: invalid port range %q", node.Line, node.Value)
+		}
+		if p.EndPort, err = strconv.Atoi(strings.TrimSpace(end)); err != nil {
+			return fmt.Errorf("line %d: invalid port range %q", node.Line, node.Value)
+		}
+		return nil
+	}
+	type plain PortSpec
+	return node.Decode((*plain)(p))
+}
+
+// maxRangePorts caps how many ports all ranges in the config may expand to,
+// so a typo like "1-65535" fails loudly instead of quietly creating tens of
+// thousands of services.
+const maxRangePorts = 1024
+
+// label formats the spec for messages: the port, or the range it covers,
+// followed by /udp for UDP ports. It also identifies the spec when config
+// files are merged, so a TCP and a UDP port 53 are kept apart.
+func (p PortSpec) label() string {
+	label := strconv.Itoa(p.Port)
+	if p.EndPort != 0 {
+		label = fmt.Sprintf("%d-%d", p.Port, p.EndPort)
+	}
+	if p.Protocol == "udp" {
+		label += "/udp"
+	}
+	return label
+}
+
+// pingOnly reports whether the server has no checks of its own and is pinged.
+func (s Server) pingOnly() bool {
+	return len(s.Ports) == 0 && s.STUN == nil && s.DNS == nil && s.SNMP == nil && s.MX == nil && s.Exec == nil && len(s.HTTPChecks) == 0
+}
+
+// expandPorts returns the server's ports with every range replaced by one
+// spec per port it covers.
+func (s Server) expandPorts() []PortSpec {
+	var ports []PortSpec
+	for _, spec := range s.Ports {
+		if spec.EndPort == 0 {
+			ports = append(ports, spec)
+			continue
+		}
+		for port := spec.Port; port <= spec.EndPort; port++ {
+			single := spec
+			single.Port, single.EndPort = port, 0
+			ports = append(ports, single)
+		}
+	}
+	return ports
+}
+
+type SMTPConfig struct {
+	Host         string `yaml:"host"`
+	Port         int    `yaml:"port"`
+	Username     string `yaml:"username"`
+	UsernameFile string `yaml:"username_file"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+	PasswordEnv  string `yaml:"password_env"` // Name of an environment variable holding the password
+	From         string `yaml:"from"`         // Sender address, defaults to the username
+
+	// Port 465 is implicit TLS and port 587 requires STARTTLS; other ports
+	// use STARTTLS when the server offers it.
+	TLSSkipVerify bool `yaml:"tls_skip_verify"` // Accept any certificate, for internal relays with self-signed ones
 }
 
 type Config struct {
-	Servers        []Server   `yaml:"servers"`
-	SMTP           SMTPConfig `yaml:"smtp"`
-	AlertRecipient string     `yaml:"alert_recipient"`
-	CheckInterval  string     `yaml:"check_interval"`
+	Servers                 []Server               `yaml:"servers"`
+	Include                 []string               `yaml:"include"`        // Globs of further server files to merge
+	MergeStrategy           string                 `yaml:"merge_strategy"` // last-wins, first-wins or error-on-conflict
+	SMTP                    SMTPConfig             `yaml:"smtp"`
+	AlertRecipient          string                 `yaml:"alert_recipient"`
+	EmailSubjectTemplate    string                 `yaml:"email_subject_template"` // Go template for the email subject
+	EmailBodyTemplate       string                 `yaml:"email_body_template"`    // Go template for the email body
+	DedupKeyTemplate        string                 `yaml:"dedup_key_template"`
+	File                    FileNotifierConfig     `yaml:"file"`
+	Kafka                   KafkaNotifierConfig    `yaml:"kafka"`
+	Slack                   SlackConfig            `yaml:"slack"`
+	Discord                 DiscordConfig          `yaml:"discord"`
+	Telegram                TelegramConfig         `yaml:"telegram"`
+	PagerDuty               PagerDutyConfig        `yaml:"pagerduty"`
+	Webhook                 WebhookConfig          `yaml:"webhook"`
+	Groups                  map[string]GroupConfig `yaml:"groups"`
+	Timeout                 string                 `yaml:"timeout"` // Per-check timeout, default 2s
+	Retries                 int                    `yaml:"retries"` // Attempts after a failed check within one cycle
+	CheckInterval           string                 `yaml:"check_interval"`
+	CheckDurationThreshold  string                 `yaml:"check_duration_threshold"`
+	CheckDurationFactor     float64                `yaml:"check_duration_factor"`
+	LatencyThreshold        string                 `yaml:"latency_threshold"` // UP checks slower than this are WARN
+	FailureThreshold        int                    `yaml:"failure_threshold"` // Consecutive DOWN results before alerting, default 1
+	ShutdownTimeout         string                 `yaml:"shutdown_timeout"`
+	MaxConcurrency          int                    `yaml:"max_concurrency"`        // Worker slots, default 50, -1 for unlimited
+	MaxPerHost              int                    `yaml:"max_per_host"`           // Concurrent checks per host, default 4, -1 for unlimited
+	CheckWeights            map[string]int         `yaml:"check_weights"`          // Slots per check type
+	HistorySize             int                    `yaml:"history_size"`           // Results kept per service for the API
+	UptimeWindow            int                    `yaml:"uptime_window"`          // Recent results the rolling uptime covers, default 100
+	UptimeInAlerts          bool                   `yaml:"uptime_in_alerts"`       // Add the rolling uptime to down alerts
+	MassFailureThreshold    float64                `yaml:"mass_failure_threshold"` // Fraction of DOWN checks that triggers a re-run, 0 to disable
+	MassFailureRecheckDelay string                 `yaml:"mass_failure_recheck_delay"`
+	CycleTimeout            string                 `yaml:"cycle_timeout"`  // Deadline for a loop cycle's checks, default the shortest interval
+	Jitter                  int                    `yaml:"jitter"`         // Spread check starts over this percentage of the loop tick
+	AlertCooldown           string                 `yaml:"alert_cooldown"` // Hold repeat alerts for a service this long
+	StartupDelay            string                 `yaml:"startup_delay"`  // Wait before the first check cycle
+	PingSuccessPolicy       PingPolicy             `yaml:"ping_success_policy"`
+	PingCount               int                    `yaml:"ping_count"`      // Packets per ping check, default 3
+	PingInterval            string                 `yaml:"ping_interval"`   // Time between packets, default 1s
+	PingPrivileged          bool                   `yaml:"ping_privileged"` // Raw ICMP sockets instead of unprivileged datagram ones
+	SourceAddress           string                 `yaml:"source_address"`  // Local IP checks are sent from
+	DryRun                  bool                   `yaml:"-"`               // Print alerts instead of sending them (-dry-run)
+	SelfMonitor             SelfMonitorConfig      `yaml:"self_monitor"`
+	ReverseDNS              bool                   `yaml:"reverse_dns"` // Show PTR names for IP hosts
+	MaintenanceWindows      []MaintenanceWindow    `yaml:"maintenance_windows"`
+	APIToken                string                 `yaml:"api_token"` // Bearer token for the API's snooze endpoints
+	APITokenFile            string                 `yaml:"api_token_file"`
+	APITokenEnv             string                 `yaml:"api_token_env"`
 }
 
 // --- Structs for Service and Status ---
@@ -47,121 +220,624 @@ type Config struct {
 type Service struct {
 	Name string
 	Host string
-	Port int // 0 for ping
+	Port int  // 0 for ping
+	SYN  bool // Raw SYN-only probe for Port
+
+	ExpectClosed bool // Port must not accept connections
+
+	PingPolicy     PingPolicy
+	PingCount      int
+	PingInterval   time.Duration
+	PingPrivileged bool
+	TLS            bool
+	OCSP           bool
+
+	CertWarnDays       int
+	InsecureSkipVerify bool
+
+	UDP      bool
+	UDPProbe string // Raw payload bytes
+
+	STUN *STUNCheck
+	DNS  *DNSCheck
+	SNMP *SNMPCheck
+	MX   *MXCheck
+	Exec *ExecCheck
+
+	GRPCHealth  bool
+	GRPCService string
+
+	GRPCReflection *GRPCReflectionCheck
+	HTTP           *HTTPCheck
+	TCPScript      *TCPScriptCheck
+	TCPProbe       *TCPProbeCheck
+
+	ActiveWindow     *TimeWindow   // nil when failures always count
+	Interval         string        // Empty for the global check interval
+	Timeout          time.Duration // Per-check network timeout
+	SourceAddress    string        // Local IP to send checks from, empty for the system's choice
+	LatencyThreshold time.Duration // Latency above which an UP check is WARN, 0 for none
+	Retries          int           // Extra attempts before a check is DOWN
+	Stage            int
+	Critical         bool
+	Group            string
+	Labels           labelSet
+
+	DedupKey string // Stable incident ID shared by all notifiers
+}
+
+// ID identifies the service in state, history and logs.
+func (s Service) ID() string {
+	if s.HTTP != nil {
+		return s.HTTP.URL
+	}
+	if s.DNS != nil {
+		id := fmt.Sprintf("dns:%s/%s", s.DNS.name(s.Host), s.DNS.recordType())
+		if s.DNS.Resolver != "" {
+			id += "@" + s.DNS.Resolver
+		}
+		return id
+	}
+	if s.MX != nil {
+		return "mx:" + s.MX.domain(s.Host)
+	}
+	if s.Exec != nil {
+		return "exec:" + s.Host + " " + s.Exec.commandLine()
+	}
+	// Checks other than a plain TCP connect carry their type, so a port
+	// checked several ways keeps separate state for each.
+	address := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	if t := s.checkType(); t != "tcp" && t != "ping" {
+		return address + "/" + t
+	}
+	return address
+}
+
+// unbracketHost strips the brackets from an IPv6 literal written as it would
+// appear in a URL ("[fe80::1]"), since hosts are joined with their port by
+// net.JoinHostPort, which adds them back, and ping wants the bare address.
+func unbracketHost(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
 }
 
+// Status is the outcome of a check. Only DOWN counts as a failure and
+// alerts; the other states describe why a check is not simply UP.
+type Status string
+
+const (
+	StatusUp       Status = "UP"
+	StatusWarn     Status = "WARN"     // Up, but with a problem worth showing
+	StatusDown     Status = "DOWN"     // Failed; alerts once the failure threshold is reached
+	StatusUnknown  Status = "UNKNOWN"  // The check could not run, e.g. for lack of privileges
+	StatusInactive Status = "INACTIVE" // Failed outside the service's active window
+	StatusSkipped  Status = "SKIPPED"  // Not run, e.g. behind a critical failure or past the cycle deadline
+)
+
 type CheckResult struct {
-	Service Service
-	Status  string // "UP" or "DOWN"
-	Error   error
+	Service   Service
+	Time      time.Time // When the check started
+	Status    Status
+	Error     error
+	Duration  time.Duration // Wall-clock time spent running the check itself
+	Latency   time.Duration // Response time of the service, e.g. ping RTT or TCP connect time
+	Throttled bool          // Waited for a free worker slot before starting
+
+	CertExpiry time.Time // Served certificate's expiry, for TLS checks
 }
 
 // --- Main Application Logic ---
 
+// Process exit codes, so scripts and CI can tell a failing service from a
+// broken setup.
+const (
+	exitDown        = 1 // A check is DOWN, with -fail-on-down or -fail-fast
+	exitConfigError = 2 // Invalid configuration or startup failure
+)
+
 func main() {
 	// --- Command-Line Flags ---
-defaultServerFile := ""
-home, err := os.UserHomeDir()
+	defaultServerFile := ""
+	home, err := os.UserHomeDir()
 	if err == nil {
 		defaultServerFile = filepath.Join(home, ".config", "infrapulse", "servers.yaml")
 	}
 
-	serverFile := flag.String("config", defaultServerFile, "Path to the servers.yaml configuration file.")
-daemon := flag.Bool("d", false, "Run in monitoring loop mode. Use 'nohup' or a service manager to run in background.")
-	
-	interval := flag.String("i", "", "Check interval in monitoring loop mode (e.g., '60s', '5m'). Overrides config file.")
+	serverFile := flag.String("config", defaultServerFile, "Path to the servers.yaml configuration file, or a directory of server files to merge.")
+	// The single-letter flags predate their long forms and keep working.
+	var daemon, once, dashboardMode bool
+	var interval string
+	flag.BoolVar(&once, "once", false, "Run the checks once and exit. This is the default.")
+	flag.BoolVar(&daemon, "daemon", false, "Run in monitoring loop mode. Use 'nohup' or a service manager to run in background.")
+	flag.BoolVar(&daemon, "watch", false, "Same as -daemon.")
+	flag.BoolVar(&daemon, "d", false, "Same as -daemon.")
+	flag.StringVar(&interval, "interval", "", "Check interval in monitoring loop mode (e.g., '60s', '5m'). Overrides config file.")
+	flag.StringVar(&interval, "i", "", "Same as -interval.")
+	flag.BoolVar(&dashboardMode, "dashboard", false, "Run in monitoring loop mode, redrawing a table of every service after each cycle instead of printing a line per result. Falls back to line output when stdout is not a terminal.")
+	stream := flag.Bool("stream", false, "In one-time mode, print results as they arrive instead of grouped by server.")
+	jsonFlag := flag.Bool("json", false, "Print each result as a JSON object per line on stdout; other output goes to stderr.")
+	logFile := flag.String("log-file", "", "Write logs and a record of every check to this file, rotated at 10MB, instead of the console.")
+	logFormat := flag.String("log-format", "text", "Log output format: 'text' or 'json'. Logs written with -log-file are always JSON.")
+	logLevel := flag.String("log-level", "info", "Minimum log level: 'debug', 'info', 'warn' or 'error'.")
+	var verbose bool
+	flag.BoolVar(&verbose, "verbose", false, "Log every check attempt with its timing and error. Same as -log-level debug.")
+	flag.BoolVar(&verbose, "v", false, "Same as -verbose.")
+	dryRun := flag.Bool("dry-run", false, "Print the alerts that would be sent instead of sending them.")
+	failFast := flag.Bool("fail-fast", false, "In one-time mode, stop remaining checks and exit non-zero on the first DOWN result.")
+	failOnDown := flag.Bool("fail-on-down", false, "In one-time mode, exit with status 1 if any check is DOWN.")
+	noInitialAlert := flag.Bool("no-initial-alert", false, "In monitoring loop mode, record the first cycle's results as a baseline without alerting.")
+	stateFlag := flag.String("state-file", "", "Path to the monitoring loop state file. Defaults to state.json next to the config file.")
+	metricsAddr := flag.String("metrics-addr", "", "Address for the Prometheus metrics endpoint in monitoring loop mode (e.g., ':9100'). Disabled if empty.")
+	apiAddr := flag.String("api-addr", "", "Address for the HTTP API in monitoring loop mode (e.g., ':8080'). Disabled if empty.")
+	dbPath := flag.String("db", "", "Record every check result in this SQLite file for trend analysis. Disabled if empty.")
+	listFlag := flag.Bool("list", false, "Print the services the configuration expands to, then exit without running any checks.")
+	noColor := flag.Bool("no-color", false, "Disable colored output.")
+	quiet := flag.Bool("quiet", false, "Print only DOWN results, without banners, summaries or notifier progress.")
+	validateFlag := flag.Bool("validate", false, "Validate the configuration, print 'config OK' or every problem found, then exit 0 or 1 without running any checks.")
+	versionFlag := flag.Bool("version", false, "Print the version, git commit and build date, then exit.")
 	flag.Parse()
 
+	if *versionFlag {
+		fmt.Println(versionString())
+		return
+	}
+
+	if *jsonFlag {
+		jsonOutput = true
+		color.Output = os.Stderr
+	}
+	if *noColor {
+		color.NoColor = true
+	}
+	quietOutput = *quiet
+	if once && daemon {
+		fmt.Fprintln(os.Stderr, "-once and -daemon cannot be used together")
+		os.Exit(exitConfigError)
+	}
+	if dashboardMode && (once || jsonOutput) {
+		fmt.Fprintln(os.Stderr, "-dashboard cannot be used with -once or -json")
+		os.Exit(exitConfigError)
+	}
+	daemon = daemon || dashboardMode
+
+	// Logging is set up before anything else logs.
+	if verbose {
+		*logLevel = "debug"
+	}
+	if *logFile != "" {
+		err = setupLogFile(*logFile, *logLevel)
+	} else {
+		err = setupLogging(*logFormat, *logLevel)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid logging configuration:", err)
+		os.Exit(exitConfigError)
+	}
+
 	// --- Load Configuration ---
 	if *serverFile == "" {
 		slog.Error("Could not find default config path. Please use the -config flag.")
-		os.Exit(1)
+		os.Exit(exitConfigError)
+	}
+
+	if *validateFlag {
+		os.Exit(checkConfig(*serverFile))
 	}
 
-	configFile := filepath.Join(filepath.Dir(*serverFile), "config.yaml")
-	cfg, err := loadConfig(*serverFile, configFile)
+	cfg, services, pool, err := loadServices(*serverFile, *dryRun)
 	if err != nil {
 		slog.Error("Error loading configuration", "error", err)
-		os.Exit(1)
+		os.Exit(exitConfigError)
+	}
+
+	if *listFlag {
+		printServiceList(services)
+		return
+	}
+
+	// --- Result Database ---
+	var db *resultsDB
+	if *dbPath != "" {
+		db, err = openResultsDB(*dbPath)
+		if err != nil {
+			slog.Error("Error opening result database", "error", err)
+			os.Exit(exitConfigError)
+		}
+		defer db.close()
 	}
 
-	// --- Create Services ---
-	services := createServices(cfg.Servers)
+	// --- Reverse DNS ---
+	if cfg.ReverseDNS {
+		reverseDNS = newReverseDNSCache()
+		reverseDNS.warm(services)
+	}
+
+	// --- Startup Delay ---
+	if cfg.StartupDelay != "" {
+		delay, err := time.ParseDuration(cfg.StartupDelay)
+		if err != nil {
+			slog.Error("Invalid startup delay", "error", err)
+			os.Exit(exitConfigError)
+		}
+		slog.Info("Delaying initial check to let co-located services start", "delay", delay)
+		time.Sleep(delay)
+	}
 
 	// --- Monitoring Loop Mode ---
-	if *daemon {
-		runMonitoringLoop(cfg, services, *interval)
+	if daemon {
+		stateFile := *stateFlag
+		if stateFile == "" {
+			stateFile = filepath.Join(configDir(*serverFile), "state.json")
+		}
+		runMonitoringLoop(cfg, services, pool, db, *serverFile, interval, *apiAddr, *metricsAddr, stateFile, *noInitialAlert, dashboardMode)
 		return
 	}
 
 	// --- One-Time Run ---
-	runOnce(cfg, services)
+	if down := runOnce(cfg, services, pool, db, *stream, *failFast); down > 0 && (*failFast || *failOnDown) {
+		os.Exit(exitDown)
+	}
+}
+
+// loadServices loads the configuration next to serverFile and builds the
+// services and worker pool from it, at startup and on every reload.
+func loadServices(serverFile string, dryRun bool) (*Config, []Service, *checkPool, error) {
+	configFile := privateConfigFile(serverFile)
+	cfg, err := loadConfig(serverFile, configFile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cfg.DryRun = dryRun
+
+	services, err := createServices(cfg)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid check configuration: %w", err)
+	}
+	if err := assignDedupKeys(services, cfg.DedupKeyTemplate); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid dedup key configuration: %w", err)
+	}
+	pool, err := newCheckPool(cfg.MaxConcurrency, cfg.MaxPerHost, cfg.CheckWeights)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid concurrency configuration: %w", err)
+	}
+	return cfg, services, pool, nil
 }
 
-func runMonitoringLoop(cfg *Config, services []Service, intervalFlag string) {
+func runMonitoringLoop(cfg *Config, services []Service, pool *checkPool, db *resultsDB, serverFile, intervalFlag, apiAddr, metricsAddr, stateFile string, noInitialAlert, dashboardMode bool) {
 	// --- Signal Handling ---
-sigChan := make(chan os.Signal, 1)
-signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
 
 	// --- State Management ---
-	statusMap := make(map[string]string)
-
-	// --- Interval ---
-	checkInterval := cfg.CheckInterval
-	if intervalFlag != "" {
-		checkInterval = intervalFlag
+	state, err := loadState(stateFile, cfg.UptimeWindow)
+	if err != nil {
+		slog.Error("Could not load state", "error", err)
+		os.Exit(exitConfigError)
 	}
-	if checkInterval == "" {
-		checkInterval = "60s" // Default to 60 seconds if not specified
+	history := newHistoryStore(cfg.HistorySize)
+	snoozes := newSnoozeStore()
+	summaries := &summaryStore{}
+	statuses := newStatusStore()
+	snoozedDown := make(map[string]bool)
+	// With -no-initial-alert the first cycle only records each service's
+	// state, so problems already present at startup never alert.
+	baselining := noInitialAlert
+	baselineDegraded := make(map[string]bool) // Degraded at the baseline, so never announced
+	cooldown := newAlertCooldown()
+
+	// --- Reloadable Settings ---
+	settings, err := newLoopSettings(cfg, services, intervalFlag)
+	if err != nil {
+		slog.Error("Invalid monitoring loop configuration", "error", err)
+		os.Exit(exitConfigError)
 	}
-	duration, err := time.ParseDuration(checkInterval)
+
+	// --- Check Duration Anomalies ---
+	durations, err := newDurationTracker(cfg.CheckDurationThreshold, cfg.CheckDurationFactor)
 	if err != nil {
-		slog.Error("Invalid check interval", "error", err)
-		os.Exit(1)
+		slog.Error("Invalid check duration threshold", "error", err)
+		os.Exit(exitConfigError)
+	}
+
+	// --- Shutdown Timeout ---
+	shutdownTimeout := 30 * time.Second
+	if cfg.ShutdownTimeout != "" {
+		shutdownTimeout, err = time.ParseDuration(cfg.ShutdownTimeout)
+		if err != nil {
+			slog.Error("Invalid shutdown timeout", "error", err)
+			os.Exit(exitConfigError)
+		}
+	}
+
+	progress(color.Cyan, "InfraPulse: Starting monitoring loop...")
+	progress(color.Cyan, "Check interval: %s", settings.interval)
+	if settings.sched.tick != settings.interval {
+		slog.Info("Per-service intervals configured", "tick", settings.sched.tick)
+	}
+
+	// --- HTTP API ---
+	if apiAddr != "" {
+		api := newAPIServer(apiAddr, cfg.APIToken, history, snoozes, summaries, statuses, state, db)
+		api.start()
+		defer api.shutdown()
+	}
+
+	// --- Prometheus Metrics ---
+	if metricsAddr != "" {
+		setMetricLabels(nil, services)
+		metrics := newMetricsServer(metricsAddr)
+		metrics.start()
+		defer metrics.shutdown()
+	}
+
+	// --- Dashboard ---
+	each := printResult
+	var dash *dashboard
+	if dashboardMode {
+		if dash = newDashboard(); dash != nil {
+			each = dash.observe
+		} else {
+			slog.Info("Standard output is not a terminal, printing results line by line instead of the dashboard")
+		}
 	}
 
-	color.Cyan("InfraPulse: Starting monitoring loop...")
-	color.Cyan("Check interval: %s", duration)
+	inFlight := newInFlightChecks()
+
+	var selfMon *selfMonitor
+	if cfg.SelfMonitor.Enabled {
+		selfMon = newSelfMonitor(cfg.SelfMonitor)
+	}
+
+	previousHealthy := true
+
+	// Cancelled on shutdown so a cycle in progress can cut its checks short;
+	// the results gathered so far are still alerted on.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Set while cycles take longer than the shortest interval, so the overrun is
+	// warned about once rather than every cycle.
+	overrunning := false
+
+	runCycle := func(due []Service) {
+		cycleStart := time.Now()
+		start := cycleStart
+		results := runCycleChecks(ctx, pool, due, inFlight, settings.cycleTimeout, settings.jitter, each)
+
+		// A near-total failure right after a healthy tick is more likely a
+		// local glitch (resolver, raw socket, network namespace) than a real
+		// outage, so confirm it with a second run before alerting.
+		fraction := downFraction(results)
+		if cfg.MassFailureThreshold > 0 && previousHealthy && fraction > cfg.MassFailureThreshold {
+			slog.Warn("Suspicious mass failure, re-running checks before alerting", "down_fraction", fraction, "delay", settings.massFailureDelay)
+			progress(color.Yellow, "%.0f%% of checks failed, re-checking in %s to rule out a local issue...", fraction*100, settings.massFailureDelay)
+			select {
+			case <-time.After(settings.massFailureDelay):
+			case <-ctx.Done():
+				// Unconfirmed, so don't alert on it.
+				return
+			}
+
+			start = time.Now()
+			rerun := runCycleChecks(ctx, pool, skipInFlight(due, inFlight), inFlight, settings.cycleTimeout, settings.jitter, each)
+			results = mergeRerun(results, rerun)
+			fraction = downFraction(results)
+			slog.Info("Mass failure re-run complete", "down_fraction", fraction, "confirmed", fraction > cfg.MassFailureThreshold)
+		}
+		previousHealthy = cfg.MassFailureThreshold == 0 || fraction <= cfg.MassFailureThreshold
+
+		summary := summarize(results, start)
+		if dash == nil {
+			summary.print()
+		}
+		summaries.set(summary)
+		statuses.update(results)
+		db.record(results)
+		if metricsAddr != "" {
+			recordMetrics(results, summary)
+		}
+
+		snoozes.expire()
+
+		var alerts []Alert
+		for _, result := range results {
+			serviceID := result.Service.ID()
+			st := state.get(serviceID)
+			state.record(serviceID, result.Status)
+			switch result.Status {
+			case StatusDown:
+				st.Failures++
+				if st.Status != StatusDown {
+					st.DownSince = time.Now()
+				}
+			case StatusUnknown:
+				// A check that could not run says nothing about the
+				// service, so its failure streak and outage carry over.
+			default:
+				st.Failures = 0
+			}
+
+			window, inMaintenance := settings.maintenance.active(result.Service, time.Now())
+
+			// A service down at the baseline counts as already past the
+			// failure threshold, so it only alerts after it has recovered
+			// and gone down again.
+			if baselining && result.Status == StatusDown {
+				st.Failures = max(st.Failures, settings.failureThreshold)
+			}
+
+			// Only outages that were alerted get a recovery notice, so a
+			// blip below the failure threshold stays silent both ways. During
+			// maintenance the notice waits until the window closes.
+			if result.Status == StatusUp && st.Alerted && !inMaintenance {
+				if !baselining {
+					alerts = append(alerts, newAlert("recovery", result, formatRecovery(result, time.Since(st.DownSince))))
+				}
+				st.Alerted = false
+				st.DownSince = time.Time{}
+			}
+			// Alert once, when the consecutive failures first reach the
+			// threshold. A failure suppressed by a snooze or maintenance
+			// window still alerts once it ends if the service is down at
+			// that point.
+			if result.Status == StatusDown && !baselining && (st.Failures == settings.failureThreshold || snoozedDown[serviceID]) {
+				if inMaintenance || snoozes.snoozed(result.Service) {
+					if !snoozedDown[serviceID] && inMaintenance {
+						slog.Info("Alert suppressed, service is in a maintenance window", "service", result.Service.Name, "id", serviceID, "window", window.String())
+					} else if !snoozedDown[serviceID] {
+						slog.Info("Alert suppressed, service is snoozed", "service", result.Service.Name, "id", serviceID)
+					}
+					snoozedDown[serviceID] = true
+				} else {
+					message := formatAlert(result)
+					if cfg.UptimeInAlerts {
+						if fraction, checks := state.uptime(serviceID); checks > 0 {
+							message += "Uptime: " + formatUptime(fraction, checks) + "\n"
+						}
+					}
+					alerts = append(alerts, newAlert("down", result, message))
+					st.Alerted = true
+					delete(snoozedDown, serviceID)
+				}
+			} else if result.Status != StatusDown {
+				delete(snoozedDown, serviceID)
+			}
+			// A service that answers but slower than its latency threshold
+			// is degraded: it alerts once, separately from outages, and gets
+			// a notice once its latency is back under the threshold.
+			slowButUp := result.Status == StatusWarn && result.Service.LatencyThreshold > 0 && result.Latency > result.Service.LatencyThreshold
+			switch {
+			case slowButUp && !st.Degraded && baselining:
+				st.Degraded = true
+				baselineDegraded[serviceID] = true
+			case slowButUp && !st.Degraded && !inMaintenance && !snoozes.snoozed(result.Service):
+				alerts = append(alerts, newAlert("degraded", result, formatDegraded(result)))
+				st.Degraded = true
+			case result.Status == StatusUp && st.Degraded && !inMaintenance:
+				if !baselineDegraded[serviceID] && !baselining {
+					alerts = append(alerts, newAlert("recovery", result, formatLatencyRecovery(result)))
+				}
+				st.Degraded = false
+				delete(baselineDegraded, serviceID)
+			case !slowButUp && result.Status != StatusUp && result.Status != StatusUnknown:
+				st.Degraded = false
+				delete(baselineDegraded, serviceID)
+			}
+			if result.Status != StatusUnknown {
+				st.Status = result.Status
+			}
+			state.set(serviceID, st)
+			history.record(serviceID, result, time.Now())
+
+			if baseline, slow := durations.observe(serviceID, result); slow && !inMaintenance && !baselining {
+				slog.Warn("Check duration is anomalous", "service", result.Service.Name, "id", serviceID, "duration", result.Duration, "baseline", baseline)
+				alerts = append(alerts, newAlert("slow", result, formatDurationAlert(result, baseline)))
+			}
+		}
+		if baselining {
+			slog.Info("Baseline recorded, alerting on changes from now on", "down", summary.Statuses[StatusDown])
+			baselining = false
+		}
+		if dash != nil {
+			dash.update(results)
+			dash.render(services, summary, state)
+		}
+
+		alerts = cooldown.filter(alerts, settings.alertCooldown, time.Now(), func(serviceID string) Status {
+			return state.get(serviceID).Status
+		})
+		alerts = collapseHostAlerts(alerts, results)
+		if selfMon != nil {
+			alerts = append(alerts, selfMon.check()...)
+		}
+
+		dispatchAlerts(cfg, alerts)
+
+		if err := state.save(stateFile); err != nil {
+			slog.Warn("Failed to save state", "path", stateFile, "error", err)
+		}
+
+		elapsed := time.Since(cycleStart)
+		if elapsed > settings.sched.shortest && !overrunning {
+			slog.Warn("Check cycle took longer than the check interval; checks due meanwhile are skipped. Raise the interval or lower timeouts and retries", "duration", elapsed.Round(time.Millisecond), "interval", settings.sched.shortest)
+		}
+		overrunning = elapsed > settings.sched.shortest
+	}
 
 	// --- Main Loop ---
-	ticker := time.NewTicker(duration)
+	ticker := time.NewTicker(settings.sched.tick)
 	defer ticker.Stop()
+	loopStart := time.Now()
 
 	for {
 		select {
-		case <-ticker.C:
-			var wg sync.WaitGroup
-			results := make(chan CheckResult)
-
-			for _, service := range services {
-				wg.Add(1)
-				go checkService(service, &wg, results)
+		case now := <-ticker.C:
+			// Derive the tick number from elapsed time, so ticks dropped
+			// during a long cycle don't shift the schedule.
+			due := settings.sched.due(services, int64((now.Sub(loopStart)+settings.sched.tick/2)/settings.sched.tick))
+			due = skipInFlight(due, inFlight)
+			if len(due) == 0 {
+				continue
 			}
-
+			cycleDone := make(chan struct{})
 			go func() {
-				wg.Wait()
-				close(results)
+				defer close(cycleDone)
+				runCycle(due)
 			}()
 
-			var alerts []string
-			for result := range results {
-				printResult(result)
-				serviceID := fmt.Sprintf("%s:%d", result.Service.Host, result.Service.Port)
-				previousStatus := statusMap[serviceID]
-				if result.Status == "DOWN" && previousStatus != "DOWN" {
-					alerts = append(alerts, formatAlert(result))
-				}
-				statusMap[serviceID] = result.Status
+			select {
+			case <-cycleDone:
+			case <-sigChan:
+				color.Cyan("\nShutting down monitoring loop, finishing the current cycle (up to %s)...", shutdownTimeout)
+				cancel()
+				awaitShutdown(cycleDone, sigChan, inFlight, shutdownTimeout)
+				return
+			}
+		case <-reloadChan:
+			// Reloads only happen between cycles, so a cycle never sees
+			// a half-swapped configuration.
+			newCfg, newServices, newPool, err := loadServices(serverFile, cfg.DryRun)
+			var newSettings loopSettings
+			if err == nil {
+				newSettings, err = newLoopSettings(newCfg, newServices, intervalFlag)
+			}
+			if err != nil {
+				slog.Error("Config reload failed, keeping the current configuration", "error", err)
+				continue
 			}
+			added, removed := diffServices(services, newServices)
+			prev := services
+			cfg, services, pool, settings = newCfg, newServices, newPool, newSettings
 
-			if len(alerts) > 0 {
-				if cfg.SMTP.Host != "" {
-					color.Yellow("Sending failure alerts via email...")
-					sendAlertEmail(cfg, alerts)
-				} else {
-					color.Yellow("SMTP configuration not found, skipping email alerts.")
+			ids := serviceIDs(services)
+			state.retain(ids)
+			state.resize(cfg.UptimeWindow)
+			history.retain(ids)
+			history.resize(cfg.HistorySize)
+			statuses.retain(ids)
+			cooldown.retain(ids)
+			if metricsAddr != "" {
+				setMetricLabels(prev, services)
+			}
+			if dash != nil {
+				dash.retain(ids)
+			}
+			for id := range snoozedDown {
+				if !ids[id] {
+					delete(snoozedDown, id)
 				}
 			}
+			if reverseDNS != nil {
+				reverseDNS.warm(services)
+			}
+
+			ticker.Reset(settings.sched.tick)
+			loopStart = time.Now()
+			slog.Info("Configuration reloaded", "services", len(services), "added", added, "removed", removed, "interval", settings.interval)
 		case <-sigChan:
 			color.Cyan("\nShutting down monitoring loop...")
 			return
@@ -169,101 +845,459 @@ signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	}
 }
 
+// awaitShutdown lets the current check cycle, including its alert dispatch,
+// finish before the loop returns. A wedged check must not hold up shutdown
+// forever, so after the timeout or on a second signal the process exits
+// immediately, logging which checks were still running.
+func awaitShutdown(cycleDone <-chan struct{}, sigChan <-chan os.Signal, inFlight *inFlightChecks, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 
-func createServices(servers []Server) []Service {
-	var services []Service
-	for _, server := range servers {
-		if len(server.Ports) == 0 {
-			services = append(services, Service{Name: server.Name, Host: server.Host, Port: 0})
-		} else {
-			for _, port := range server.Ports {
-				services = append(services, Service{Name: server.Name, Host: server.Host, Port: port})
-			}
-		}
+	select {
+	case <-cycleDone:
+		return
+	case <-timer.C:
+		slog.Error("Shutdown timeout exceeded, forcing exit", "timeout", timeout, "running", inFlight.running())
+	case <-sigChan:
+		slog.Error("Received second signal, forcing exit", "running", inFlight.running())
 	}
-	return services
+	os.Exit(1)
+}
+
+// inFlightChecks tracks which checks of the current cycle have not returned yet.
+type inFlightChecks struct {
+	mu      sync.Mutex
+	started map[string]time.Time
 }
 
-func runOnce(cfg *Config, services []Service) {
-	var wg sync.WaitGroup
-	results := make(chan CheckResult)
+func newInFlightChecks() *inFlightChecks {
+	return &inFlightChecks{started: make(map[string]time.Time)}
+}
+
+func (f *inFlightChecks) start(serviceID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.started[serviceID] = time.Now()
+}
+
+func (f *inFlightChecks) finish(serviceID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.started, serviceID)
+}
 
-	color.Cyan("InfraPulse: Starting health checks...")
+// has reports whether a check of the service is still running.
+func (f *inFlightChecks) has(serviceID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.started[serviceID]
+	return ok
+}
 
-	for _, service := range services {
-		wg.Add(1)
-		go checkService(service, &wg, results)
+// running lists the in-flight checks and how long each has been running.
+func (f *inFlightChecks) running() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	running := make([]string, 0, len(f.started))
+	for serviceID, started := range f.started {
+		running = append(running, fmt.Sprintf("%s (%s)", serviceID, time.Since(started).Round(time.Millisecond)))
+	}
+	sort.Strings(running)
+	return running
+}
+
+// downFraction is the share of results that are DOWN.
+func downFraction(results []CheckResult) float64 {
+	if len(results) == 0 {
+		return 0
+	}
+	down := 0
+	for _, result := range results {
+		if result.Status == StatusDown {
+			down++
+		}
 	}
+	return float64(down) / float64(len(results))
+}
 
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+// defaultCheckTimeout bounds each check when no timeout is configured.
+const defaultCheckTimeout = 2 * time.Second
 
-	var alerts []string
-	for result := range results {
-		printResult(result)
-		if result.Status == "DOWN" {
-			alerts = append(alerts, formatAlert(result))
+// retryBackoff is the wait before the first retry of a failed check,
+// doubling for each further retry.
+const retryBackoff = 250 * time.Millisecond
+
+func createServices(cfg *Config) ([]Service, error) {
+	globalTimeout := defaultCheckTimeout
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q", cfg.Timeout)
 		}
+		globalTimeout = d
+	}
+	var globalLatencyThreshold time.Duration
+	if cfg.LatencyThreshold != "" {
+		d, err := time.ParseDuration(cfg.LatencyThreshold)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid latency_threshold %q", cfg.LatencyThreshold)
+		}
+		globalLatencyThreshold = d
 	}
 
-	if len(alerts) > 0 {
-		if cfg.SMTP.Host != "" {
-			color.Yellow("Sending failure alerts via email...")
-			sendAlertEmail(cfg, alerts)
-		} else {
-			color.Yellow("SMTP configuration not found, skipping email alerts.")
+	var services []Service
+	for _, server := range cfg.Servers {
+		server.Host = unbracketHost(server.Host)
+		labels := newLabelSet(server.Labels)
+		timeout := globalTimeout
+		if server.Timeout != "" {
+			d, err := time.ParseDuration(server.Timeout)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid timeout %q for %s", server.Timeout, server.Name)
+			}
+			timeout = d
+		}
+		latencyThreshold := globalLatencyThreshold
+		if server.LatencyThreshold != "" {
+			d, err := time.ParseDuration(server.LatencyThreshold)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid latency_threshold %q for %s", server.LatencyThreshold, server.Name)
+			}
+			latencyThreshold = d
+		}
+		source := cfg.SourceAddress
+		if server.SourceAddress != "" {
+			source = server.SourceAddress
+		}
+		if server.pingOnly() {
+			policy := cfg.PingSuccessPolicy
+			if server.PingSuccessPolicy != "" {
+				policy = server.PingSuccessPolicy
+			}
+			count, interval, privileged, err := pingSettings(cfg, server)
+			if err != nil {
+				return nil, err
+			}
+			services = append(services, Service{Name: server.Name, Host: server.Host, Port: 0, PingPolicy: policy, PingCount: count, PingInterval: interval, PingPrivileged: privileged, ActiveWindow: server.ActiveWindow, Interval: server.Interval, Timeout: timeout, SourceAddress: source, LatencyThreshold: latencyThreshold, Retries: cfg.Retries, Stage: server.Stage, Critical: server.Critical, Group: server.Group, Labels: labels})
+		}
+		for _, port := range server.expandPorts() {
+			probe := port.Probe
+			if port.ProbeHex != "" {
+				payload, err := hex.DecodeString(port.ProbeHex)
+				if err != nil {
+					return nil, fmt.Errorf("invalid probe_hex for %s port %d: %w", server.Name, port.Port, err)
+				}
+				probe = string(payload)
+			}
+			window := server.ActiveWindow
+			if port.ActiveWindow != nil {
+				window = port.ActiveWindow
+			}
+			if port.Expect != "" {
+				port.TCPScript = &TCPScriptCheck{Steps: []ScriptStep{{Send: port.Send, Expect: port.Expect}}}
+			}
+			services = append(services, Service{Name: server.Name, Host: server.Host, Port: port.Port, UDP: port.Protocol == "udp", UDPProbe: probe, SYN: server.SYN, ExpectClosed: port.State == "closed", TLS: port.TLS, OCSP: port.OCSP, CertWarnDays: port.CertWarnDays, InsecureSkipVerify: server.InsecureSkipVerify, GRPCHealth: port.GRPC, GRPCService: port.GRPCService, GRPCReflection: port.GRPCReflection, TCPScript: port.TCPScript, TCPProbe: port.TCPProbe, ActiveWindow: window, Interval: server.Interval, Timeout: timeout, SourceAddress: source, LatencyThreshold: latencyThreshold, Retries: cfg.Retries, Stage: server.Stage, Critical: server.Critical, Group: server.Group, Labels: labels})
+		}
+		if server.STUN != nil {
+			services = append(services, Service{Name: server.Name, Host: server.Host, Port: server.STUN.port(), STUN: server.STUN, ActiveWindow: server.ActiveWindow, Interval: server.Interval, Timeout: timeout, SourceAddress: source, LatencyThreshold: latencyThreshold, Retries: cfg.Retries, Stage: server.Stage, Critical: server.Critical, Group: server.Group, Labels: labels})
+		}
+		if server.DNS != nil {
+			services = append(services, Service{Name: server.Name, Host: server.Host, Port: dnsDefaultPort, DNS: server.DNS, ActiveWindow: server.ActiveWindow, Interval: server.Interval, Timeout: timeout, SourceAddress: source, LatencyThreshold: latencyThreshold, Retries: cfg.Retries, Stage: server.Stage, Critical: server.Critical, Group: server.Group, Labels: labels})
+		}
+		if server.SNMP != nil {
+			services = append(services, Service{Name: server.Name, Host: server.Host, Port: server.SNMP.port(), SNMP: server.SNMP, ActiveWindow: server.ActiveWindow, Interval: server.Interval, Timeout: timeout, SourceAddress: source, LatencyThreshold: latencyThreshold, Retries: cfg.Retries, Stage: server.Stage, Critical: server.Critical, Group: server.Group, Labels: labels})
+		}
+		if server.MX != nil {
+			services = append(services, Service{Name: server.Name, Host: server.Host, Port: server.MX.port(), MX: server.MX, ActiveWindow: server.ActiveWindow, Interval: server.Interval, Timeout: timeout, SourceAddress: source, LatencyThreshold: latencyThreshold, Retries: cfg.Retries, Stage: server.Stage, Critical: server.Critical, Group: server.Group, Labels: labels})
+		}
+		if server.Exec != nil {
+			services = append(services, Service{Name: server.Name, Host: server.Host, Exec: server.Exec, ActiveWindow: server.ActiveWindow, Interval: server.Interval, Timeout: timeout, LatencyThreshold: latencyThreshold, Retries: cfg.Retries, Stage: server.Stage, Critical: server.Critical, Group: server.Group, Labels: labels})
+		}
+		for i := range server.HTTPChecks {
+			check := &server.HTTPChecks[i]
+			services = append(services, Service{Name: server.Name, Host: server.Host, Port: check.port(), HTTP: check, ActiveWindow: server.ActiveWindow, Interval: server.Interval, Timeout: timeout, SourceAddress: source, LatencyThreshold: latencyThreshold, Retries: cfg.Retries, Stage: server.Stage, Critical: server.Critical, Group: server.Group, Labels: labels})
 		}
 	}
 
-	color.Cyan("All checks complete.")
+	// Services sharing an ID would share their state, history, snoozes and
+	// cooldown, so one of them could hide the other's outage.
+	owners := make(map[string]string, len(services))
+	var duplicates []string
+	for _, s := range services {
+		id := s.ID()
+		if first, ok := owners[id]; ok {
+			duplicates = append(duplicates, fmt.Sprintf("%s is checked twice, by %s and %s", id, first, s.Name))
+			continue
+		}
+		owners[id] = s.Name
+	}
+	if len(duplicates) > 0 {
+		return nil, fmt.Errorf("duplicate services: %s", strings.Join(duplicates, "; "))
+	}
+	return services, nil
 }
 
+// runOnce checks every service once, prints the results and sends alerts. It
+// returns the number of DOWN checks. With failFast it stops at the first one.
+func runOnce(cfg *Config, services []Service, pool *checkPool, db *resultsDB, stream, failFast bool) int {
+	progress(color.Cyan, "InfraPulse: Starting health checks...")
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Now()
+	results := pool.runStages(ctx, services, nil, 0)
+	maintenance := newMaintenanceSchedule(cfg)
 
-func checkService(service Service, wg *sync.WaitGroup, results chan<- CheckResult) {
+	var alerts []Alert
+	var collected []CheckResult
+	for result := range results {
+		if stream {
+			printResult(result)
+		}
+		collected = append(collected, result)
+		if result.Status == StatusDown {
+			if window, ok := maintenance.active(result.Service, time.Now()); ok {
+				slog.Info("Alert suppressed, service is in a maintenance window", "service", result.Service.Name, "id", result.Service.ID(), "window", window.String())
+			} else {
+				alerts = append(alerts, newAlert("down", result, formatAlert(result)))
+			}
+			if failFast {
+				// Checks not yet started are skipped; ones in progress are
+				// abandoned by the exit.
+				cancel()
+				if !stream {
+					printResult(result)
+				}
+				color.Red("Fail-fast: %s (%s) is DOWN, stopping remaining checks.", result.Service.Name, result.Service.ID())
+				db.record(collected)
+				dispatchAlerts(cfg, alerts)
+				return 1
+			}
+		}
+	}
+	if !stream {
+		printGrouped(services, collected)
+	}
+	summary := summarize(collected, start)
+	summary.print()
+	db.record(collected)
+
+	dispatchAlerts(cfg, collapseHostAlerts(alerts, collected))
+
+	progress(color.Cyan, "All checks complete.")
+	return summary.Statuses[StatusDown]
+}
+
+func checkService(ctx context.Context, service Service, throttled bool, wg *sync.WaitGroup, results chan<- CheckResult) {
 	defer wg.Done()
 
-	if service.Port == 0 { // Ping
-		pinger, err := probing.NewPinger(service.Host)
-		if err != nil {
-			results <- CheckResult{Service: service, Status: "DOWN", Error: err}
+	checker := newChecker(service)
+	start := time.Now()
+	result := runAttempt(ctx, checker, service, 1)
+	// Retry a failure within the cycle to ride out a momentary blip; each
+	// attempt gets the full timeout.
+	for attempt := 0; result.Status == StatusDown && attempt < service.Retries; attempt++ {
+		select {
+		case <-time.After(retryBackoff << attempt):
+		case <-ctx.Done():
 			return
 		}
-		pinger.Count = 3
-		pinger.Timeout = 2 * time.Second
-		err = pinger.Run()
-		if err != nil || pinger.Statistics().PacketsRecv == 0 {
-			results <- CheckResult{Service: service, Status: "DOWN", Error: err}
-		} else {
-			results <- CheckResult{Service: service, Status: "UP"}
-		}
-	} else { // TCP Port Check
-		address := fmt.Sprintf("%s:%d", service.Host, service.Port)
-		conn, err := net.DialTimeout("tcp", address, 2*time.Second)
-		if err != nil {
-			results <- CheckResult{Service: service, Status: "DOWN", Error: err}
-		} else {
-			conn.Close()
-			results <- CheckResult{Service: service, Status: "UP"}
-		}
+		result = runAttempt(ctx, checker, service, attempt+2)
+	}
+	// A check cut short by shutdown says nothing about the service.
+	if ctx.Err() != nil {
+		return
+	}
+	result.Time = start
+	result.Duration = time.Since(start)
+	result.Throttled = throttled
+	// Checks without a more precise measure report their whole duration.
+	if result.Latency == 0 {
+		result.Latency = result.Duration
+	}
+	if result.Status == StatusUp && service.LatencyThreshold > 0 && result.Latency > service.LatencyThreshold {
+		result.Status = StatusWarn
+		result.Error = fmt.Errorf("latency %s exceeds latency_threshold %s", formatLatency(result.Latency), service.LatencyThreshold)
+	}
+	// Outside its active window the check still runs, but a failure is
+	// expected and must not alert.
+	if result.Status == StatusDown && service.ActiveWindow != nil && !service.ActiveWindow.contains(start) {
+		result.Status = StatusInactive
+	}
+	select {
+	case results <- result:
+	case <-ctx.Done():
+	}
+}
+
+// runAttempt runs one attempt of a check. At debug level, as with -verbose, it
+// logs each attempt's outcome, duration and raw error, plus DNS and connect
+// time for HTTP checks, so a flaky check can be followed attempt by attempt.
+func runAttempt(ctx context.Context, checker Checker, service Service, attempt int) CheckResult {
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return checker.Check(ctx)
+	}
+
+	// Dialing may race several addresses at once.
+	var mu sync.Mutex
+	var dnsStart, connectStart time.Time
+	var dns, connect time.Duration
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { mu.Lock(); dnsStart = time.Now(); mu.Unlock() },
+		DNSDone:  func(httptrace.DNSDoneInfo) { mu.Lock(); dns = time.Since(dnsStart); mu.Unlock() },
+		ConnectStart: func(string, string) {
+			mu.Lock()
+			if connectStart.IsZero() {
+				connectStart = time.Now()
+			}
+			mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			mu.Lock()
+			if err == nil && connect == 0 {
+				connect = time.Since(connectStart)
+			}
+			mu.Unlock()
+		},
+	})
+
+	start := time.Now()
+	result := checker.Check(ctx)
+	attrs := []any{"service", service.Name, "id", service.ID(), "check", service.checkType(), "attempt", attempt, "of", service.Retries + 1, "status", result.Status, "duration", time.Since(start)}
+	mu.Lock()
+	if dns > 0 {
+		attrs = append(attrs, "dns", dns)
+	}
+	if connect > 0 {
+		attrs = append(attrs, "connect", connect)
+	}
+	mu.Unlock()
+	if result.Latency > 0 {
+		attrs = append(attrs, "latency", result.Latency)
 	}
+	if result.Error != nil {
+		attrs = append(attrs, "error", result.Error.Error())
+	}
+	slog.Debug("Check attempt", attrs...)
+	return result
 }
 
 func printResult(result CheckResult) {
-	if result.Service.Port == 0 { // Ping
-		if result.Status == "UP" {
-			color.Green("  [UP] %s (%s): Host is up", result.Service.Name, result.Service.Host)
+	if logToFile {
+		logResult(result)
+	}
+	if quietOutput && result.Status != StatusDown {
+		return
+	}
+	if jsonOutput {
+		printResultJSON(result)
+		return
+	}
+	if result.Service.Port == 0 && result.Service.Exec == nil { // Ping
+		if result.Status == StatusSkipped {
+			color.HiBlack("  [SKIPPED] %s (%s): %v", result.Service.Name, displayHost(result.Service.Host), result.Error)
+		} else if result.Status == StatusUnknown {
+			color.Magenta("  [UNKNOWN] %s (%s): %v", result.Service.Name, displayHost(result.Service.Host), result.Error)
+		} else if result.Status == StatusInactive {
+			color.HiBlack("  [INACTIVE] %s (%s): Host is down outside its active window %s", result.Service.Name, displayHost(result.Service.Host), result.Service.ActiveWindow)
+		} else if result.Status == StatusUp {
+			color.Green("  [UP] %s (%s): Host is up (%s)", result.Service.Name, displayHost(result.Service.Host), formatLatency(result.Latency))
+		} else if result.Status == StatusWarn {
+			color.Yellow("  [WARN] %s (%s): %v", result.Service.Name, displayHost(result.Service.Host), result.Error)
 		} else {
-			color.Red("  [DOWN] %s (%s): Host is down", result.Service.Name, result.Service.Host)
+			color.Red("  [DOWN] %s (%s): Host is down", result.Service.Name, displayHost(result.Service.Host))
 		}
-	} else { // Port
-		if result.Status == "UP" {
-			color.Green("    - Port %d: [UP]", result.Service.Port)
-		} else {
-			color.Red("    - Port %d: [DOWN]", result.Service.Port)
+		return
+	}
+
+	label := fmt.Sprintf("Port %d", result.Service.Port)
+	if result.Service.UDP {
+		label += "/udp"
+	} else if result.Service.HTTP != nil {
+		label = "HTTP " + result.Service.HTTP.URL
+	} else if result.Service.STUN != nil {
+		label = fmt.Sprintf("STUN %d", result.Service.Port)
+	} else if result.Service.DNS != nil {
+		label = fmt.Sprintf("DNS %s %s", result.Service.DNS.recordType(), result.Service.DNS.name(result.Service.Host))
+	} else if result.Service.SNMP != nil {
+		label = fmt.Sprintf("SNMP %d", result.Service.Port)
+	} else if result.Service.MX != nil {
+		label = "MX " + result.Service.MX.domain(result.Service.Host)
+	} else if result.Service.Exec != nil {
+		label = "Exec " + result.Service.Exec.commandLine()
+	} else if result.Service.GRPCHealth {
+		label += " (gRPC health)"
+	} else if result.Service.GRPCReflection != nil {
+		label += " (gRPC)"
+	} else if result.Service.TCPScript != nil {
+		label += " (script)"
+	} else if result.Service.TCPProbe != nil {
+		label += " (probe)"
+	} else if result.Service.TLS {
+		label += " (TLS)"
+	} else if result.Service.ExpectClosed {
+		label += " (expect closed)"
+	}
+
+	switch result.Status {
+	case StatusUp:
+		color.Green("    - %s: [UP] (%s)", label, formatLatency(result.Latency))
+	case StatusWarn:
+		color.Yellow("    - %s: [WARN] %v", label, result.Error)
+	case StatusSkipped:
+		color.HiBlack("    - %s: [SKIPPED] %v", label, result.Error)
+	case StatusUnknown:
+		color.Magenta("    - %s: [UNKNOWN] %v", label, result.Error)
+	case StatusInactive:
+		color.HiBlack("    - %s: [INACTIVE] outside active window %s", label, result.Service.ActiveWindow)
+	default:
+		color.Red("    - %s: [DOWN]", label)
+	}
+}
+
+// formatLatency rounds a latency for display: whole milliseconds, or
+// microseconds below 1ms so fast local services don't all show as 0s.
+func formatLatency(d time.Duration) string {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// printGrouped prints results in config order, each server's checks under a
+// single host line, so multi-port hosts don't interleave with each other.
+func printGrouped(services []Service, results []CheckResult) {
+	if jsonOutput || logToFile {
+		for _, result := range results {
+			printResult(result)
+		}
+		return
+	}
+	order := make(map[Service]int, len(services))
+	for i, service := range services {
+		if _, ok := order[service]; !ok {
+			order[service] = i
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return order[results[i].Service] < order[results[j].Service]
+	})
+
+	var lastName, lastHost string
+	headed := false
+	for _, result := range results {
+		// Under -quiet only DOWN results are printed, so only their servers
+		// get a header.
+		if !quietOutput || result.Status == StatusDown {
+			newServer := !headed || result.Service.Name != lastName || result.Service.Host != lastHost
+			headed = true
+			lastName, lastHost = result.Service.Name, result.Service.Host
+			// A ping result already doubles as the host line.
+			if newServer && (result.Service.Port != 0 || result.Service.Exec != nil) {
+				color.White("  %s (%s)", result.Service.Name, displayHost(result.Service.Host))
+			}
 		}
+		printResult(result)
 	}
 }
 
@@ -276,90 +1310,182 @@ func formatAlert(result CheckResult) string {
 		errorMsg = "No specific error message."
 	}
 
+	if result.Service.HTTP != nil {
+		return fmt.Sprintf("HTTP Check Alert\n\nService: %s\nURL: %s\nTime: %s\nError: %s\nIncident: %s\n", result.Service.Name, result.Service.HTTP.URL, timestamp, errorMsg, result.Service.DedupKey)
+	}
+	if result.Service.DNS != nil {
+		return fmt.Sprintf("DNS Resolution Alert\n\nService: %s\nName: %s\nRecord Type: %s\nTime: %s\nError: %s\nIncident: %s\n", result.Service.Name, result.Service.DNS.name(result.Service.Host), result.Service.DNS.recordType(), timestamp, errorMsg, result.Service.DedupKey)
+	}
+	if result.Service.ExpectClosed && errors.Is(result.Error, errPortOpen) {
+		return fmt.Sprintf("Port Exposure Alert\n\nService: %s\nHost: %s\nPort: %d\nTime: %s\nDetails: Port unexpectedly open. It is expected to be closed and now accepts connections.\nIncident: %s\n", result.Service.Name, displayHost(result.Service.Host), result.Service.Port, timestamp, result.Service.DedupKey)
+	}
+	if result.Service.SNMP != nil {
+		return fmt.Sprintf("SNMP Device Alert\n\nService: %s\nHost: %s\nPort: %d\nTime: %s\nError: %s\nIncident: %s\n", result.Service.Name, displayHost(result.Service.Host), result.Service.Port, timestamp, errorMsg, result.Service.DedupKey)
+	}
+	if result.Service.MX != nil {
+		return fmt.Sprintf("Mail Server Alert\n\nService: %s\nDomain: %s\nTime: %s\nError: %s\nIncident: %s\n", result.Service.Name, result.Service.MX.domain(result.Service.Host), timestamp, errorMsg, result.Service.DedupKey)
+	}
+	if result.Service.STUN != nil {
+		return fmt.Sprintf("STUN Server Down Alert\n\nService: %s\nHost: %s\nPort: %d\nTime: %s\nDetails: STUN binding request failed.\nError: %s\nIncident: %s\n", result.Service.Name, displayHost(result.Service.Host), result.Service.Port, timestamp, errorMsg, result.Service.DedupKey)
+	}
+	if !result.CertExpiry.IsZero() {
+		return fmt.Sprintf("TLS Certificate Alert\n\nService: %s\nHost: %s\nPort: %d\nTime: %s\nCertificate Expires: %s\nDays Remaining: %d\nError: %s\nIncident: %s\n", result.Service.Name, displayHost(result.Service.Host), result.Service.Port, timestamp, result.CertExpiry.Format(time.RFC1123), certDaysLeft(result.CertExpiry), errorMsg, result.Service.DedupKey)
+	}
+	if result.Service.Exec != nil {
+		return fmt.Sprintf("Script Check Alert\n\nService: %s\nHost: %s\nCommand: %s\nTime: %s\nError: %s\nIncident: %s\n", result.Service.Name, displayHost(result.Service.Host), result.Service.Exec.commandLine(), timestamp, errorMsg, result.Service.DedupKey)
+	}
 	if result.Service.Port == 0 {
-		return fmt.Sprintf("Host Down Alert\n\nHost: %s (%s)\nTime: %s\nDetails: Ping failed.\nError: %s\n", result.Service.Name, result.Service.Host, timestamp, errorMsg)
+		return fmt.Sprintf("Host Down Alert\n\nHost: %s (%s)\nTime: %s\nDetails: Ping failed.\nError: %s\nIncident: %s\n", result.Service.Name, displayHost(result.Service.Host), timestamp, errorMsg, result.Service.DedupKey)
 	}
-	return fmt.Sprintf("Service Down Alert\n\nService: %s\nHost: %s\nPort: %d\nTime: %s\nError: %s\n", result.Service.Name, result.Service.Host, result.Service.Port, timestamp, errorMsg)
+	return fmt.Sprintf("Service Down Alert\n\nService: %s\nHost: %s\nPort: %d\nTime: %s\nError: %s\nIncident: %s\n", result.Service.Name, displayHost(result.Service.Host), result.Service.Port, timestamp, errorMsg, result.Service.DedupKey)
 }
 
 // loadConfig reads and merges server and SMTP configurations.
-func loadConfig(serverFile, configFile string) (*Config, error) {
-	// Load server list
+func loadConfig(serverPath, configFile string) (*Config, error) {
+	// Load server list, from a file or every file of a directory
+	files, err := serverFiles(serverPath)
+	if err != nil {
+		return nil, err
+	}
+	serverFile := files[0]
 	serverData, err := os.ReadFile(serverFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read %s: %w", serverFile, err)
 	}
-	var serverConfig struct {
-		Servers       []Server `yaml:"servers"`
-		CheckInterval string   `yaml:"check_interval"`
-	}
-	if err := yaml.Unmarshal(serverData, &serverConfig); err != nil {
+	// Operational settings live alongside the server list.
+	cfg := &Config{}
+	if err := unmarshalConfig(serverFile, serverData, cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse %s: %w", serverFile, err)
 	}
+	secretPathsRelativeTo(cfg, filepath.Dir(serverFile))
+	if err := loadIncludes(cfg, serverFile, files[1:]); err != nil {
+		return nil, err
+	}
 
 	// Load private config (SMTP, etc.)
 	configData, err := os.ReadFile(configFile)
-	if err != nil {
-		// If the config file is not found, we just return the server config
-		// and assume no email alerts are needed.
-		if os.IsNotExist(err) {
-			return &Config{
-				Servers:       serverConfig.Servers,
-				CheckInterval: serverConfig.CheckInterval,
-			}, nil
-		}
+	if err != nil && !os.IsNotExist(err) {
 		return nil, fmt.Errorf("failed to read %s: %w", configFile, err)
 	}
-	var privateConfig struct {
-		SMTP           SMTPConfig `yaml:"smtp"`
-		AlertRecipient string     `yaml:"alert_recipient"`
+	// If the config file is not found, we just use the server config and
+	// assume no email alerts are needed.
+	if err == nil {
+		var privateConfig struct {
+			SMTP                 SMTPConfig             `yaml:"smtp"`
+			AlertRecipient       string                 `yaml:"alert_recipient"`
+			EmailSubjectTemplate string                 `yaml:"email_subject_template"`
+			EmailBodyTemplate    string                 `yaml:"email_body_template"`
+			DedupKeyTemplate     string                 `yaml:"dedup_key_template"`
+			File                 FileNotifierConfig     `yaml:"file"`
+			Kafka                KafkaNotifierConfig    `yaml:"kafka"`
+			Slack                SlackConfig            `yaml:"slack"`
+			Discord              DiscordConfig          `yaml:"discord"`
+			Telegram             TelegramConfig         `yaml:"telegram"`
+			PagerDuty            PagerDutyConfig        `yaml:"pagerduty"`
+			Webhook              WebhookConfig          `yaml:"webhook"`
+			Groups               map[string]GroupConfig `yaml:"groups"`
+			APIToken             string                 `yaml:"api_token"`
+			APITokenFile         string                 `yaml:"api_token_file"`
+			APITokenEnv          string                 `yaml:"api_token_env"`
+		}
+		if err := unmarshalConfig(configFile, configData, &privateConfig); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", configFile, err)
+		}
+
+		// Combine into a single config struct
+		cfg.SMTP = privateConfig.SMTP
+		cfg.AlertRecipient = privateConfig.AlertRecipient
+		cfg.EmailSubjectTemplate = privateConfig.EmailSubjectTemplate
+		cfg.EmailBodyTemplate = privateConfig.EmailBodyTemplate
+		cfg.DedupKeyTemplate = privateConfig.DedupKeyTemplate
+		cfg.File = privateConfig.File
+		cfg.Kafka = privateConfig.Kafka
+		cfg.Slack = privateConfig.Slack
+		cfg.Discord = privateConfig.Discord
+		cfg.Telegram = privateConfig.Telegram
+		cfg.PagerDuty = privateConfig.PagerDuty
+		cfg.Webhook = privateConfig.Webhook
+		cfg.Groups = privateConfig.Groups
+		cfg.APIToken = privateConfig.APIToken
+		cfg.APITokenFile = privateConfig.APITokenFile
+		cfg.APITokenEnv = privateConfig.APITokenEnv
+		secretPathsRelativeTo(cfg, filepath.Dir(configFile))
 	}
-	if err := yaml.Unmarshal(configData, &privateConfig); err != nil {
-		return nil, fmt.Errorf("failed to parse %s: %w", configFile, err)
+
+	if err := resolveSecretFiles(cfg); err != nil {
+		return nil, err
 	}
 
-	// Combine into a single config struct
-	fullConfig := &Config{
-		Servers:        serverConfig.Servers,
-		SMTP:           privateConfig.SMTP,
-		AlertRecipient: privateConfig.AlertRecipient,
-		CheckInterval:  serverConfig.CheckInterval,
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
 	}
 
-	return fullConfig, nil
+	return cfg, nil
 }
 
-// sendAlertEmail sends a consolidated email with all failure alerts.
-func sendAlertEmail(cfg *Config, alerts []string) {
-	if cfg.AlertRecipient == "" {
-		slog.Warn("Email alert failed: AlertRecipient is not set in config.yaml")
-		return
+// formatRecovery describes a service that is back UP after an alerted outage.
+func formatRecovery(result CheckResult, downFor time.Duration) string {
+	timestamp := time.Now().Format(time.RFC1123)
+	target := fmt.Sprintf("Host: %s\nPort: %d", displayHost(result.Service.Host), result.Service.Port)
+	if result.Service.HTTP != nil {
+		target = "URL: " + result.Service.HTTP.URL
+	} else if result.Service.Exec != nil {
+		target = fmt.Sprintf("Host: %s\nCommand: %s", displayHost(result.Service.Host), result.Service.Exec.commandLine())
+	} else if result.Service.Port == 0 {
+		target = "Host: " + displayHost(result.Service.Host)
 	}
+	return fmt.Sprintf("Service Recovered\n\nService: %s\n%s\nTime: %s\nDowntime: %s\nIncident: %s\n", result.Service.Name, target, timestamp, downFor.Round(time.Second), result.Service.DedupKey)
+}
 
-	from := cfg.SMTP.Username
-	password := cfg.SMTP.Password
-	to := strings.Split(cfg.AlertRecipient, ",")
-	for i, email := range to {
-		to[i] = strings.TrimSpace(email)
-	}
-	smtpHost := cfg.SMTP.Host
-	smtpPort := cfg.SMTP.Port
+// formatDegraded describes a service that is up but slower than its latency
+// threshold.
+func formatDegraded(result CheckResult) string {
+	timestamp := time.Now().Format(time.RFC1123)
+	return fmt.Sprintf("Service Degraded\n\nService: %s\nID: %s\nTime: %s\nLatency: %s\nThreshold: %s\nDetails: The service is up but responding slower than its latency threshold.\nIncident: %s\n", result.Service.Name, result.Service.ID(), timestamp, formatLatency(result.Latency), result.Service.LatencyThreshold, result.Service.DedupKey)
+}
 
-	subject := "Subject: InfraPulse Alert: Service Degradation Detected\n"
-	body := "One or more services are down:\n\n"
-	body += strings.Join(alerts, "\n---------------------------------\n\n")
-	
-	message := []byte(subject + body)
+func formatLatencyRecovery(result CheckResult) string {
+	timestamp := time.Now().Format(time.RFC1123)
+	return fmt.Sprintf("Latency Recovered\n\nService: %s\nID: %s\nTime: %s\nLatency: %s\nThreshold: %s\nIncident: %s\n", result.Service.Name, result.Service.ID(), timestamp, formatLatency(result.Latency), result.Service.LatencyThreshold, result.Service.DedupKey)
+}
 
+// sendAlertEmail sends outages and degraded services in one email, each in
+// its own section, unless email templates say otherwise.
+func sendAlertEmail(cfg *Config, alerts []Alert) error {
+	var down, degraded []Alert
+	for _, alert := range alerts {
+		if alert.Kind == "degraded" {
+			degraded = append(degraded, alert)
+		} else {
+			down = append(down, alert)
+		}
+	}
+	var sections []string
+	if len(down) > 0 {
+		sections = append(sections, "One or more services are down:\n\n"+joinAlertMessages(down))
+	}
+	if len(degraded) > 0 {
+		sections = append(sections, "One or more services are degraded (up, but slower than their latency threshold):\n\n"+joinAlertMessages(degraded))
+	}
+	data := newEmailData("alert", alerts)
+	data.Subject = "InfraPulse Alert: Service Degradation Detected"
+	data.Body = strings.Join(sections, "\n=================================\n\n")
+	subject, body := renderEmail(cfg, data)
+	return sendEmail(cfg, subject, body)
+}
 
-	auth := smtp.PlainAuth("", from, password, smtpHost)
-	addr := fmt.Sprintf("%s:%d", smtpHost, smtpPort)
+func sendRecoveryEmail(cfg *Config, alerts []Alert) error {
+	data := newEmailData("recovery", alerts)
+	data.Subject = "InfraPulse: Service Recovered"
+	data.Body = "One or more services have recovered:\n\n" + joinAlertMessages(alerts)
+	subject, body := renderEmail(cfg, data)
+	return sendEmail(cfg, subject, body)
+}
 
-	err := smtp.SendMail(addr, auth, from, to, message)
-	if err != nil {
-		slog.Error("Email alert failed to send", "error", err)
-		return
+func joinAlertMessages(alerts []Alert) string {
+	messages := make([]string, len(alerts))
+	for i, alert := range alerts {
+		messages[i] = alert.Message
 	}
-
-	slog.Info("Email alert sent successfully.")
-}
\ No newline at end of file
+	return strings.Join(messages, "\n---------------------------------\n\n")
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestUnbracketHost(t *testing.T) {
+	tests := []struct{ host, want string }{
+		{"[fe80::1]", "fe80::1"},
+		{"fe80::1", "fe80::1"},
+		{"[2001:db8::10]", "2001:db8::10"},
+		{"192.0.2.1", "192.0.2.1"},
+		{"example.com", "example.com"},
+		{"[", "["},
+	}
+	for _, tt := range tests {
+		if got := unbracketHost(tt.host); got != tt.want {
+			t.Errorf("unbracketHost(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestCreateServicesBracketedIPv6(t *testing.T) {
+	data := []byte(`servers:
+  - name: v6
+    host: "[fe80::1]"
+    ports:
+      - 443
+      - port: 53
+        protocol: udp
+`)
+	var cfg Config
+	if err := unmarshalConfig("servers.yaml", data, &cfg); err != nil {
+		t.Fatalf("unmarshalConfig: %v", err)
+	}
+	services, err := createServices(&cfg)
+	if err != nil {
+		t.Fatalf("createServices: %v", err)
+	}
+	wantIDs := []string{"[fe80::1]:443", "[fe80::1]:53/udp"}
+	wantTargets := []string{"[fe80::1]:443", "[fe80::1]:53"}
+	if len(services) != len(wantIDs) {
+		t.Fatalf("createServices returned %d services, want %d", len(services), len(wantIDs))
+	}
+	for i, service := range services {
+		if service.Host != "fe80::1" {
+			t.Errorf("services[%d].Host = %q, want fe80::1", i, service.Host)
+		}
+		if id := service.ID(); id != wantIDs[i] {
+			t.Errorf("services[%d].ID() = %q, want %q", i, id, wantIDs[i])
+		}
+		if target := dashboardTarget(service); target != wantTargets[i] {
+			t.Errorf("dashboardTarget(services[%d]) = %q, want %q", i, target, wantTargets[i])
+		}
+	}
+}
+
+func TestCreateServicesIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "one port checked several ways",
+			servers: `
+  - name: edge
+    host: edge.example.com
+    ports:
+      - 3478
+      - port: 3478
+        protocol: udp
+      - port: 3478
+        tls: true
+    stun: {}
+`,
+			want: []string{"edge.example.com:3478", "edge.example.com:3478/udp", "edge.example.com:3478/tls", "edge.example.com:3478/stun"},
+		},
+		{
+			name: "same URL twice",
+			servers: `
+  - name: web
+    host: example.com
+    http_checks:
+      - url: https://example.com/health
+      - url: https://example.com/health
+        expected_status: 200
+`,
+			wantErr: true,
+		},
+		{
+			name: "same port on two servers",
+			servers: `
+  - name: db
+    host: db.example.com
+    ports: [5432]
+  - name: db-replica-typo
+    host: db.example.com
+    ports: [5432]
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			if err := unmarshalConfig("servers.yaml", []byte("servers:"+tt.servers), &cfg); err != nil {
+				t.Fatalf("unmarshalConfig: %v", err)
+			}
+			services, err := createServices(&cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("createServices succeeded, want a duplicate service error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("createServices: %v", err)
+			}
+			var ids []string
+			for _, service := range services {
+				ids = append(ids, service.ID())
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("IDs = %q, want %q", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestPortSpecUnmarshalAndExpand(t *testing.T) {
+	tests := []struct {
+		name       string
+		ports      string
+		wantLabels []string // label() of each spec as parsed
+		wantPorts  []string // label() of each expanded port
+		wantErr    bool
+	}{
+		{"bare port", "[22]", []string{"22"}, []string{"22"}, false},
+		{"range", `["8000-8002"]`, []string{"8000-8002"}, []string{"8000", "8001", "8002"}, false},
+		{"range with spaces", `["9000 - 9001"]`, []string{"9000-9001"}, []string{"9000", "9001"}, false},
+		{"single port range", `["443-443"]`, []string{"443-443"}, []string{"443"}, false},
+		{"mapping", "[{port: 53, protocol: udp}, 53]", []string{"53/udp", "53"}, []string{"53/udp", "53"}, false},
+		{"mixed", `[22, "80-81", {port: 443, tls: true}]`, []string{"22", "80-81", "443"}, []string{"22", "80", "81", "443"}, false},
+		{"not a number", "[ssh]", nil, nil, true},
+		{"bad range end", `["80-x"]`, nil, nil, true},
+		{"bad range start", `["-90"]`, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var server Server
+			err := yaml.Unmarshal([]byte("ports: "+tt.ports), &server)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Unmarshal accepted %s as %+v", tt.ports, server.Ports)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			var labels, ports []string
+			for _, spec := range server.Ports {
+				labels = append(labels, spec.label())
+			}
+			for _, spec := range server.expandPorts() {
+				if spec.EndPort != 0 {
+					t.Errorf("expanded port %d still has EndPort %d", spec.Port, spec.EndPort)
+				}
+				ports = append(ports, spec.label())
+			}
+			if !slices.Equal(labels, tt.wantLabels) {
+				t.Errorf("parsed = %q, want %q", labels, tt.wantLabels)
+			}
+			if !slices.Equal(ports, tt.wantPorts) {
+				t.Errorf("expanded = %q, want %q", ports, tt.wantPorts)
+			}
+		})
+	}
+}
+
+func TestExpandPortsKeepsOptions(t *testing.T) {
+	server := Server{Ports: []PortSpec{{Port: 6000, EndPort: 6001, Protocol: "udp", Probe: "ping"}}}
+	for _, spec := range server.expandPorts() {
+		if spec.Protocol != "udp" || spec.Probe != "ping" {
+			t.Errorf("port %d lost the range's options: %+v", spec.Port, spec)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MaintenanceWindow is a period of planned work during which alerts for the
+// affected services are held back. Checks keep running and results are still
+// printed and logged. A window either recurs daily (optionally only on some
+// weekdays) or runs once between two timestamps.
+type MaintenanceWindow struct {
+	Name     string      `yaml:"name"`
+	Window   *TimeWindow `yaml:"window"`   // Recurring "HH:MM-HH:MM", local time
+	Days     []string    `yaml:"days"`     // Weekdays a recurring window applies on, every day if empty
+	Start    time.Time   `yaml:"start"`    // One-off window start, RFC 3339
+	End      time.Time   `yaml:"end"`      // One-off window end, exclusive
+	Services []string    `yaml:"services"` // Service names or IDs, every service if empty
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) > 3 {
+		s = s[:3]
+	}
+	day, ok := weekdays[s]
+	return day, ok
+}
+
+// validate reports what is wrong with the window's definition, if anything.
+func (m MaintenanceWindow) validate() error {
+	oneOff := !m.Start.IsZero() || !m.End.IsZero()
+	switch {
+	case m.Window != nil && oneOff:
+		return fmt.Errorf("set either window or start/end, not both")
+	case m.Window == nil && !oneOff:
+		return fmt.Errorf("window or start/end is required")
+	case oneOff && (m.Start.IsZero() || m.End.IsZero()):
+		return fmt.Errorf("start and end must both be set")
+	case oneOff && !m.End.After(m.Start):
+		return fmt.Errorf("end must be after start")
+	case oneOff && len(m.Days) > 0:
+		return fmt.Errorf("days only apply to a recurring window")
+	}
+	for _, day := range m.Days {
+		if _, ok := parseWeekday(day); !ok {
+			return fmt.Errorf("unknown day %q", day)
+		}
+	}
+	return nil
+}
+
+func (m MaintenanceWindow) String() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	if m.Window != nil {
+		return m.Window.String()
+	}
+	return m.Start.Format(time.RFC3339) + " to " + m.End.Format(time.RFC3339)
+}
+
+// covers reports whether the window applies to service at time t.
+func (m MaintenanceWindow) covers(service Service, t time.Time) bool {
+	if len(m.Services) > 0 && !slices.Contains(m.Services, service.Name) && !slices.Contains(m.Services, service.ID()) {
+		return false
+	}
+	if m.Window == nil {
+		return !t.Before(m.Start) && t.Before(m.End)
+	}
+	if !m.Window.contains(t) {
+		return false
+	}
+	if len(m.Days) == 0 {
+		return true
+	}
+	// The part of a window that wraps past midnight belongs to the day the
+	// window started on.
+	day := t.Weekday()
+	if m.Window.start > m.Window.end && t.Hour()*60+t.Minute() < m.Window.end {
+		day = t.AddDate(0, 0, -1).Weekday()
+	}
+	return slices.ContainsFunc(m.Days, func(d string) bool {
+		weekday, _ := parseWeekday(d)
+		return weekday == day
+	})
+}
+
+// maintenanceSchedule is every configured maintenance window: the global ones
+// and those set on individual servers, which only cover that server.
+type maintenanceSchedule []MaintenanceWindow
+
+func newMaintenanceSchedule(cfg *Config) maintenanceSchedule {
+	schedule := maintenanceSchedule(slices.Clone(cfg.MaintenanceWindows))
+	for _, server := range cfg.Servers {
+		for _, window := range server.MaintenanceWindows {
+			window.Services = []string{server.Name}
+			schedule = append(schedule, window)
+		}
+	}
+	return schedule
+}
+
+// active returns the maintenance window covering service at time t, if any.
+func (s maintenanceSchedule) active(service Service, t time.Time) (MaintenanceWindow, bool) {
+	for _, window := range s {
+		if window.covers(service, t) {
+			return window, true
+		}
+	}
+	return MaintenanceWindow{}, false
+}
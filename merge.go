@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Merge strategies for settings and servers defined in more than one file.
+const (
+	mergeLastWins  = "last-wins"
+	mergeFirstWins = "first-wins"
+	mergeError     = "error-on-conflict"
+)
+
+// loadIncludes merges siblings, the other server files of a -config
+// directory, and then the files matched by cfg.Include into cfg. Includes are
+// merged in the order the patterns are listed and, within a pattern, by file
+// name. Relative patterns are resolved against the directory of serverFile.
+func loadIncludes(cfg *Config, serverFile string, siblings []string) error {
+	if len(cfg.Include) == 0 && len(siblings) == 0 {
+		return nil
+	}
+	m := &configMerger{strategy: cfg.MergeStrategy, origins: make(map[string]string)}
+	switch m.strategy {
+	case "":
+		m.strategy = mergeLastWins
+	case mergeLastWins, mergeFirstWins, mergeError:
+	default:
+		return fmt.Errorf("invalid merge_strategy %q, want %s, %s or %s", m.strategy, mergeLastWins, mergeFirstWins, mergeError)
+	}
+	m.recordOrigins(cfg.Servers, serverFile)
+
+	files := slices.Clone(siblings)
+	for _, pattern := range cfg.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(serverFile), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid include pattern %q: %w", pattern, err)
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		part := &Config{}
+		if err := unmarshalConfig(file, data, part); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		secretPathsRelativeTo(part, filepath.Dir(file))
+		if len(part.Include) > 0 || part.MergeStrategy != "" {
+			slog.Warn("include and merge_strategy are only read from the main config file", "file", file)
+			part.Include, part.MergeStrategy = nil, ""
+		}
+		if err := m.merge(cfg, part, file); err != nil {
+			return err
+		}
+		slog.Debug("Merged config file", "file", file, "servers", len(part.Servers))
+	}
+	return nil
+}
+
+// configMerger folds config files together. Servers with the same name are
+// merged field by field: ports and HTTP checks are unioned, and any other
+// field set differently in both is a conflict resolved by the strategy.
+type configMerger struct {
+	strategy string
+	origins  map[string]string // File each server's port or HTTP check was first defined in
+}
+
+// serviceKey identifies a port or HTTP check of a named server across files.
+func serviceKey(server, service string) string {
+	return server + "/" + service
+}
+
+func (m *configMerger) recordOrigins(servers []Server, source string) {
+	for _, server := range servers {
+		for _, port := range server.Ports {
+			m.origins[serviceKey(server.Name, port.label())] = source
+		}
+		for _, check := range server.HTTPChecks {
+			m.origins[serviceKey(server.Name, check.URL)] = source
+		}
+	}
+}
+
+// reportDuplicate logs a port or HTTP check defined by a server of the same
+// name in two files, which usually means two teams claim the same service.
+func (m *configMerger) reportDuplicate(server, service, source string) {
+	slog.Warn("Duplicate service definition", "server", server, "service", service, "first_defined_in", m.origins[serviceKey(server, service)], "also_in", source)
+}
+
+func (m *configMerger) merge(dst, src *Config, source string) error {
+	if err := m.mergeFields(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem(), "", source, "servers"); err != nil {
+		return err
+	}
+
+	byName := make(map[string]int, len(dst.Servers))
+	for i, server := range dst.Servers {
+		byName[server.Name] = i
+	}
+	for _, server := range src.Servers {
+		i, ok := byName[server.Name]
+		if !ok {
+			byName[server.Name] = len(dst.Servers)
+			dst.Servers = append(dst.Servers, server)
+			m.recordOrigins([]Server{server}, source)
+			continue
+		}
+		if err := m.mergeServer(&dst.Servers[i], server, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *configMerger) mergeServer(dst *Server, src Server, source string) error {
+	prefix := fmt.Sprintf("servers[%s].", dst.Name)
+	if err := m.mergeFields(reflect.ValueOf(dst).Elem(), reflect.ValueOf(&src).Elem(), prefix, source, "ports", "http_checks"); err != nil {
+		return err
+	}
+
+	for _, port := range src.Ports {
+		i := slices.IndexFunc(dst.Ports, func(p PortSpec) bool { return p.label() == port.label() })
+		if i < 0 {
+			dst.Ports = append(dst.Ports, port)
+			m.origins[serviceKey(dst.Name, port.label())] = source
+			continue
+		}
+		m.reportDuplicate(dst.Name, port.label(), source)
+		field := fmt.Sprintf("%sports[%s]", prefix, port.label())
+		if err := m.resolve(reflect.ValueOf(&dst.Ports[i]).Elem(), reflect.ValueOf(port), field, source); err != nil {
+			return err
+		}
+	}
+	for _, check := range src.HTTPChecks {
+		i := slices.IndexFunc(dst.HTTPChecks, func(c HTTPCheck) bool { return c.URL == check.URL })
+		if i < 0 {
+			dst.HTTPChecks = append(dst.HTTPChecks, check)
+			m.origins[serviceKey(dst.Name, check.URL)] = source
+			continue
+		}
+		m.reportDuplicate(dst.Name, check.URL, source)
+		field := fmt.Sprintf("%shttp_checks[%s]", prefix, check.URL)
+		if err := m.resolve(reflect.ValueOf(&dst.HTTPChecks[i]).Elem(), reflect.ValueOf(check), field, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeFields merges every exported field of src into dst except the ones
+// whose yaml keys are listed in skip.
+func (m *configMerger) mergeFields(dst, src reflect.Value, prefix, source string, skip ...string) error {
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Type().Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if !field.IsExported() || key == "" || key == "-" || slices.Contains(skip, key) {
+			continue
+		}
+		if err := m.resolve(dst.Field(i), src.Field(i), prefix+key, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve applies a single value from source. An unset value never overrides,
+// and a value equal to the current one is not a conflict.
+func (m *configMerger) resolve(dst, src reflect.Value, field, source string) error {
+	if src.IsZero() || reflect.DeepEqual(dst.Interface(), src.Interface()) {
+		return nil
+	}
+	if dst.IsZero() {
+		dst.Set(src)
+		return nil
+	}
+	switch m.strategy {
+	case mergeError:
+		return fmt.Errorf("%s: %s is already set to a different value", source, field)
+	case mergeFirstWins:
+		slog.Info("Config conflict resolved", "field", field, "strategy", m.strategy, "kept", describe(dst), "ignored", describe(src), "from", source)
+	default:
+		slog.Info("Config conflict resolved", "field", field, "strategy", m.strategy, "kept", describe(src), "replaced", describe(dst), "from", source)
+		dst.Set(src)
+	}
+	return nil
+}
+
+// describe renders a config value for conflict logs.
+func describe(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	return fmt.Sprintf("%+v", v.Interface())
+}
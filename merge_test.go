@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestConfigMergerKeepsProtocolsApart(t *testing.T) {
+	dst := &Config{Servers: []Server{{Name: "dns", Host: "192.0.2.53", Ports: []PortSpec{{Port: 53}}}}}
+	src := &Config{Servers: []Server{{Name: "dns", Ports: []PortSpec{
+		{Port: 53, Protocol: "udp", Probe: "ping"},
+		{Port: 53, Protocol: "tcp", TLS: true},
+	}}}}
+	m := &configMerger{strategy: mergeLastWins, origins: make(map[string]string)}
+	m.recordOrigins(dst.Servers, "a.yaml")
+	if err := m.merge(dst, src, "b.yaml"); err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+
+	ports := dst.Servers[0].Ports
+	if len(ports) != 2 {
+		t.Fatalf("merged ports = %+v, want TCP and UDP 53", ports)
+	}
+	if ports[0].Protocol != "tcp" || !ports[0].TLS {
+		t.Errorf("TCP port 53 = %+v, want the later file's tls: true", ports[0])
+	}
+	if ports[1].Protocol != "udp" || ports[1].Probe != "ping" {
+		t.Errorf("UDP port 53 = %+v, want the later file's probe", ports[1])
+	}
+}
+
+func TestConfigMergerErrorOnConflict(t *testing.T) {
+	dst := &Config{Servers: []Server{{Name: "web", Ports: []PortSpec{{Port: 443, TLS: true, CertWarnDays: 14}}}}}
+	src := &Config{Servers: []Server{{Name: "web", Ports: []PortSpec{{Port: 443, CertWarnDays: 30}, {Port: 443, Protocol: "udp"}}}}}
+	m := &configMerger{strategy: mergeError, origins: make(map[string]string)}
+	if err := m.merge(dst, src, "b.yaml"); err == nil {
+		t.Fatal("merge succeeded, want a conflict on cert_warn_days")
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// serviceMetrics are the per-service metrics. Besides serviceMetricLabels
+// they carry every label key set on any server, empty for servers without
+// it, as Prometheus needs the same label names on every series of a metric.
+type serviceMetrics struct {
+	keys    []string
+	up      *prometheus.GaugeVec
+	latency *prometheus.HistogramVec
+}
+
+// serviceMetricLabels label every per-service series. name, host and port
+// alone are shared by several http_checks of a server, or a TCP and a UDP
+// check of one port, so the check type and the service ID tell them apart.
+var serviceMetricLabels = []string{"name", "host", "port", "check", "id"}
+
+// serviceCollector serves the current serviceMetrics. It is registered once
+// and describes no metrics up front, which makes it an unchecked collector:
+// the registry remembers the label names of the metrics it is told about,
+// even after unregistering, so a reload that changes the label keys could
+// not register new metrics in place of the old.
+type serviceCollector struct {
+	mu      sync.RWMutex
+	metrics *serviceMetrics
+}
+
+var svcCollector = &serviceCollector{}
+
+func init() {
+	prometheus.MustRegister(svcCollector)
+}
+
+func (c *serviceCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c *serviceCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.metrics != nil {
+		c.metrics.up.Collect(ch)
+		c.metrics.latency.Collect(ch)
+	}
+}
+
+var (
+	cycleWallTime = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "infrapulse_cycle_duration_seconds",
+		Help: "Wall-clock time of the last check cycle.",
+	})
+	cycleSlowest = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "infrapulse_cycle_slowest_check_seconds",
+		Help: "Duration of the slowest check in the last cycle.",
+	})
+	cycleThrottled = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "infrapulse_cycle_throttled_checks",
+		Help: "Checks in the last cycle that waited for a free worker slot.",
+	})
+)
+
+// labels returns the label values of service's series.
+func (m *serviceMetrics) labels(service Service) prometheus.Labels {
+	labels := prometheus.Labels{"name": service.Name, "host": service.Host, "port": strconv.Itoa(service.Port), "check": service.checkType(), "id": service.ID()}
+	for _, key := range m.keys {
+		labels[key] = ""
+	}
+	for _, label := range service.Labels.labels() {
+		labels[label.Key] = label.Value
+	}
+	return labels
+}
+
+// series identifies a series by its label values, in the metric's label
+// order.
+func (m *serviceMetrics) series(labels prometheus.Labels) string {
+	values := make([]string, 0, len(serviceMetricLabels)+len(m.keys))
+	for _, name := range serviceMetricLabels {
+		values = append(values, labels[name])
+	}
+	for _, key := range m.keys {
+		values = append(values, labels[key])
+	}
+	return strings.Join(values, "\x00")
+}
+
+// setMetricLabels sets up the per-service metrics with the label keys of
+// services. If the keys differ from the current ones the metrics are
+// replaced, which resets them. Otherwise the series of prev, the services
+// before a reload, that no longer match a service are deleted, so a removed
+// or renamed service doesn't keep exporting its last value.
+func setMetricLabels(prev, services []Service) {
+	keySet := make(map[string]bool)
+	for _, service := range services {
+		for _, label := range service.Labels.labels() {
+			keySet[label.Key] = true
+		}
+	}
+	keys := slices.Sorted(maps.Keys(keySet))
+
+	svcCollector.mu.Lock()
+	defer svcCollector.mu.Unlock()
+	if svcCollector.metrics != nil {
+		if m := svcCollector.metrics; slices.Equal(m.keys, keys) {
+			current := make(map[string]bool, len(services))
+			for _, service := range services {
+				current[m.series(m.labels(service))] = true
+			}
+			for _, service := range prev {
+				if labels := m.labels(service); !current[m.series(labels)] {
+					m.up.Delete(labels)
+					m.latency.Delete(labels)
+				}
+			}
+			return
+		}
+		slog.Info("Service label keys changed, resetting per-service metrics", "labels", keys)
+	}
+	names := append(slices.Clone(serviceMetricLabels), keys...)
+	svcCollector.metrics = &serviceMetrics{
+		keys: keys,
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "infrapulse_service_up",
+			Help: "Whether the service was UP at the last check (1) or not (0).",
+		}, names),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "infrapulse_check_latency_seconds",
+			Help:    "Response latency of successful checks.",
+			Buckets: prometheus.DefBuckets,
+		}, names),
+	}
+}
+
+// recordMetrics updates the Prometheus metrics after a check cycle.
+func recordMetrics(results []CheckResult, summary runSummary) {
+	svcCollector.mu.RLock()
+	svcMetrics := svcCollector.metrics
+	svcCollector.mu.RUnlock()
+	for _, result := range results {
+		labels := svcMetrics.labels(result.Service)
+		up := 0.0
+		if result.Status == StatusUp {
+			up = 1
+		}
+		svcMetrics.up.With(labels).Set(up)
+		if result.Status == StatusUp || result.Status == StatusWarn {
+			svcMetrics.latency.With(labels).Observe(result.Latency.Seconds())
+		}
+	}
+
+	cycleWallTime.Set(summary.WallTime.Seconds())
+	cycleThrottled.Set(float64(summary.Throttled))
+	if summary.Slowest != nil {
+		cycleSlowest.Set(summary.Slowest.Duration.Seconds())
+	}
+}
+
+// metricsServer serves /metrics for Prometheus in monitoring loop mode.
+type metricsServer struct {
+	server *http.Server
+}
+
+func newMetricsServer(addr string) *metricsServer {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return &metricsServer{server: &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}}
+}
+
+// start serves in the background; like the API, a listen failure is logged
+// and monitoring continues.
+func (m *metricsServer) start() {
+	go func() {
+		slog.Info("Metrics server listening", "addr", m.server.Addr)
+		if err := m.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Metrics server failed", "error", err)
+		}
+	}()
+}
+
+func (m *metricsServer) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.server.Shutdown(ctx); err != nil {
+		slog.Warn("Metrics server did not shut down cleanly", "error", err)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSetMetricLabelsDropsRemovedSeries(t *testing.T) {
+	t.Cleanup(func() { svcCollector.metrics = nil })
+	db := Service{Name: "db", Host: "db.example.com", Port: 5432, Labels: newLabelSet(map[string]string{"team": "data"})}
+	web := Service{Name: "web", Host: "web.example.com", Port: 443, Labels: newLabelSet(map[string]string{"team": "web"})}
+	record := func(services ...Service) {
+		var results []CheckResult
+		for _, service := range services {
+			results = append(results, CheckResult{Service: service, Status: StatusUp})
+		}
+		recordMetrics(results, runSummary{})
+	}
+
+	setMetricLabels(nil, []Service{db, web})
+	record(db, web)
+	if n := testutil.CollectAndCount(svcCollector, "infrapulse_service_up"); n != 2 {
+		t.Fatalf("%d up series, want 2", n)
+	}
+
+	// Same label keys: web is removed and db changes team.
+	moved := db
+	moved.Labels = newLabelSet(map[string]string{"team": "platform"})
+	setMetricLabels([]Service{db, web}, []Service{moved})
+	if n := testutil.CollectAndCount(svcCollector, "infrapulse_service_up"); n != 0 {
+		t.Errorf("%d up series left after the reload, want 0", n)
+	}
+	record(moved)
+	if n := testutil.CollectAndCount(svcCollector, "infrapulse_service_up"); n != 1 {
+		t.Errorf("%d up series, want 1", n)
+	}
+	if n := testutil.CollectAndCount(svcCollector, "infrapulse_check_latency_seconds"); n != 1 {
+		t.Errorf("%d latency series, want 1", n)
+	}
+}
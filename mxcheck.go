@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MXCheck checks that a domain can receive mail: it looks up the domain's MX
+// records and opens an SMTP conversation with the most preferred exchanger,
+// going as far as MAIL FROM without sending anything. A mail server that
+// accepts TCP connections but greets with an error or rejects senders passes
+// a port check; it doesn't pass this one.
+type MXCheck struct {
+	Domain   string `yaml:"domain"`    // Defaults to the server's host
+	Port     int    `yaml:"port"`      // Defaults to 25
+	HELO     string `yaml:"helo"`      // Name to greet with in EHLO, defaults to "localhost"
+	MailFrom string `yaml:"mail_from"` // Envelope sender for MAIL FROM, the null sender if empty
+}
+
+const mxDefaultPort = 25
+
+func (c *MXCheck) port() int {
+	if c.Port == 0 {
+		return mxDefaultPort
+	}
+	return c.Port
+}
+
+// domain returns the mail domain to check for a server.
+func (c *MXCheck) domain(host string) string {
+	if c.Domain == "" {
+		return host
+	}
+	return c.Domain
+}
+
+// MXChecker runs a server's mx check.
+type MXChecker struct{ Service Service }
+
+// Check resolves the domain's most preferred mail exchanger and reports the
+// service DOWN if there is none, it can't be reached, or it doesn't get
+// through the greeting, EHLO and MAIL FROM with success replies. Latency
+// covers the lookup and the whole conversation.
+func (c MXChecker) Check(ctx context.Context) CheckResult {
+	service, timeout := c.Service, c.Service.Timeout
+	check := service.MX
+	domain := check.domain(service.Host)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	records, err := net.DefaultResolver.LookupMX(ctx, domain)
+	if err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("MX lookup failed: %w", err)}
+	}
+	if len(records) == 0 {
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("no MX records for %s", domain)}
+	}
+	// LookupMX sorts by preference.
+	exchanger := strings.TrimSuffix(records[0].Host, ".")
+	if exchanger == "" {
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("%s publishes a null MX and accepts no mail", domain)}
+	}
+
+	if err := smtpHandshake(ctx, service.dialer("tcp", timeout), exchanger, check); err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("%s: %w", exchanger, err)}
+	}
+	return CheckResult{Service: service, Status: StatusUp, Latency: time.Since(start)}
+}
+
+// smtpHandshake opens an SMTP session with host and goes as far as MAIL FROM,
+// then resets and quits so nothing is sent.
+func smtpHandshake(ctx context.Context, dialer *net.Dialer, host string, check *MXCheck) error {
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(check.port())))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return fmt.Errorf("greeting: %w", err)
+	}
+	helo := check.HELO
+	if helo == "" {
+		helo = "localhost"
+	}
+	if err := client.Hello(helo); err != nil {
+		return fmt.Errorf("EHLO: %w", err)
+	}
+	if err := client.Mail(check.MailFrom); err != nil {
+		return fmt.Errorf("MAIL FROM: %w", err)
+	}
+	if err := client.Reset(); err != nil {
+		return fmt.Errorf("RSET: %w", err)
+	}
+	client.Quit()
+	return nil
+}
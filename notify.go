@@ -0,0 +1,291 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Alert is a single notification about a service, carrying both the
+// human-readable message used by email and the structured result for
+// channels that need machine-readable output.
+type Alert struct {
+	Kind     string // "down", "recovery", "degraded", "slow" or "self"
+	Result   CheckResult
+	Message  string
+	Time     time.Time
+	Affected []CheckResult // Every failed check of a host-down alert, Result being the first
+}
+
+// newAlert returns an alert with message, followed by the service's labels if
+// it has any.
+func newAlert(kind string, result CheckResult, message string) Alert {
+	if labels := result.Service.Labels; labels != "" {
+		message += "Labels: " + labels.String() + "\n"
+	}
+	return Alert{Kind: kind, Result: result, Message: message, Time: time.Now()}
+}
+
+// target names what the alert is about in chat titles: the host for a
+// host-down alert, otherwise the service ID.
+func (a Alert) target() string {
+	if len(a.Affected) > 0 {
+		return displayHost(a.Result.Service.Host)
+	}
+	return a.Result.Service.ID()
+}
+
+// dispatchAlerts sends a batch of alerts through every configured
+// notification channel, routing the alerts of grouped servers to their
+// group's recipients and channels.
+func dispatchAlerts(cfg *Config, alerts []Alert) {
+	if len(alerts) == 0 {
+		retryPendingAlerts()
+		return
+	}
+
+	if cfg.DryRun {
+		color.Magenta("=== DRY RUN: %d alert(s) would be sent, no notifications delivered ===", len(alerts))
+		for _, alert := range alerts {
+			if group := alert.Result.Service.Group; group != "" {
+				color.Magenta("--- %s (group %s) ---", alert.Kind, group)
+			} else {
+				color.Magenta("--- %s ---", alert.Kind)
+			}
+			fmt.Fprintln(color.Output, alert.Message)
+		}
+		return
+	}
+
+	groups, byGroup := groupAlerts(alerts)
+	for _, group := range groups {
+		if group != "" {
+			progress(color.Yellow, "Alerts for group %s:", group)
+		}
+		dispatchGroup(cfg.forGroup(group), group, byGroup[group])
+	}
+	retryPendingAlerts()
+}
+
+// dispatchGroup sends one group's alerts, with cfg already resolved for the
+// group. Each group's channels back off independently.
+func dispatchGroup(cfg *Config, group string, alerts []Alert) {
+	notify := func(channel string, send func(alerts []Alert) error) {
+		if group != "" {
+			channel += "[" + group + "]"
+		}
+		notifyChannel(channel, alerts, send)
+	}
+
+	if cfg.File.Path != "" {
+		notify("file", func(alerts []Alert) error {
+			progress(color.Yellow, "Writing alerts to %s...", cfg.File.Path)
+			return writeAlertFile(cfg.File, alerts)
+		})
+	}
+
+	if len(cfg.Kafka.Brokers) > 0 {
+		notify("kafka", func(alerts []Alert) error {
+			progress(color.Yellow, "Producing alerts to Kafka topic %s...", cfg.Kafka.Topic)
+			return sendAlertKafka(cfg.Kafka, alerts)
+		})
+	}
+
+	if cfg.Slack.WebhookURL != "" {
+		notify("slack", func(alerts []Alert) error {
+			progress(color.Yellow, "Sending alerts to Slack...")
+			return sendSlackAlert(cfg, alerts)
+		})
+	}
+
+	if cfg.Discord.WebhookURL != "" {
+		notify("discord", func(alerts []Alert) error {
+			progress(color.Yellow, "Sending alerts to Discord...")
+			return sendDiscordAlert(cfg, alerts)
+		})
+	}
+
+	if cfg.Telegram.BotToken != "" {
+		notify("telegram", func(alerts []Alert) error {
+			progress(color.Yellow, "Sending alerts to Telegram...")
+			return sendTelegramAlert(cfg.Telegram, alerts)
+		})
+	}
+
+	if cfg.PagerDuty.RoutingKey != "" {
+		notify("pagerduty", func(alerts []Alert) error {
+			progress(color.Yellow, "Sending events to PagerDuty...")
+			return sendPagerDutyAlert(cfg.PagerDuty, alerts)
+		})
+	}
+
+	if cfg.Webhook.URL != "" {
+		notify("webhook", func(alerts []Alert) error {
+			progress(color.Yellow, "Sending alerts to webhook...")
+			return sendWebhookAlert(cfg.Webhook, alerts)
+		})
+	}
+
+	if cfg.SMTP.Host != "" {
+		notify("email", func(alerts []Alert) error {
+			var failures, recoveries []Alert
+			for _, alert := range alerts {
+				if alert.Kind == "recovery" {
+					recoveries = append(recoveries, alert)
+				} else {
+					failures = append(failures, alert)
+				}
+			}
+			var errs []error
+			if len(failures) > 0 {
+				progress(color.Yellow, "Sending failure alerts via email...")
+				errs = append(errs, sendAlertEmail(cfg, failures))
+			}
+			if len(recoveries) > 0 {
+				progress(color.Yellow, "Sending recovery notices via email...")
+				errs = append(errs, sendRecoveryEmail(cfg, recoveries))
+			}
+			return errors.Join(errs...)
+		})
+	} else {
+		progress(color.Yellow, "SMTP configuration not found, skipping email alerts.")
+	}
+}
+
+// Notifier backoff: after a failed send a channel is skipped for a while,
+// doubling on every further failure up to the cap, so a dead SMTP server or
+// webhook isn't hammered every cycle and doesn't flood the logs. A successful
+// send resets it. Alerts that fail to send or come due while a channel is
+// backing off are kept for that channel and sent together once the backoff
+// expires, since a down alert fires only once per outage and losing it, or a
+// PagerDuty resolve, can't be made up for later. The checks and the other
+// channels carry on meanwhile.
+const (
+	notifierBackoffInitial = time.Minute
+	notifierBackoffMax     = 30 * time.Minute
+)
+
+// maxPendingAlerts caps the alerts kept for a channel that keeps failing;
+// beyond it the oldest are dropped.
+const maxPendingAlerts = 1000
+
+type notifierState struct {
+	failures int
+	retryAt  time.Time
+	pending  []Alert             // Alerts not delivered yet, oldest first
+	send     func([]Alert) error // Latest send function, to deliver pending alerts with
+	sending  bool                // A send is in progress
+	dropped  int                 // Pending alerts dropped over maxPendingAlerts
+}
+
+var (
+	notifierMu     sync.Mutex
+	notifierStates = make(map[string]*notifierState)
+)
+
+// notifyChannel queues alerts for the named channel and sends everything
+// pending unless the channel is backing off, updating its backoff state from
+// the outcome.
+func notifyChannel(channel string, alerts []Alert, send func([]Alert) error) {
+	notifierMu.Lock()
+	st, ok := notifierStates[channel]
+	if !ok {
+		st = &notifierState{}
+		notifierStates[channel] = st
+	}
+	st.send = send
+	st.queue(channel, alerts)
+	if st.sending || time.Now().Before(st.retryAt) {
+		notifierMu.Unlock()
+		slog.Debug("Notifier is backing off, alerts kept until it retries", "channel", channel, "pending", len(st.pending), "retry_at", st.retryAt)
+		return
+	}
+	notifierMu.Unlock()
+	deliverPending(channel, st)
+}
+
+// retryPendingAlerts sends the pending alerts of every channel whose backoff
+// has expired, so they go out even if no new alert comes due for it.
+func retryPendingAlerts() {
+	notifierMu.Lock()
+	var due []string
+	for channel, st := range notifierStates {
+		if len(st.pending) > 0 && !st.sending && !time.Now().Before(st.retryAt) {
+			due = append(due, channel)
+		}
+	}
+	notifierMu.Unlock()
+	slices.Sort(due)
+	for _, channel := range due {
+		notifierMu.Lock()
+		st := notifierStates[channel]
+		notifierMu.Unlock()
+		deliverPending(channel, st)
+	}
+}
+
+// queue appends alerts to the channel's pending alerts, dropping the oldest
+// over maxPendingAlerts. Called with notifierMu held.
+func (st *notifierState) queue(channel string, alerts []Alert) {
+	st.pending = append(st.pending, alerts...)
+	if over := len(st.pending) - maxPendingAlerts; over > 0 {
+		st.pending = slices.Delete(st.pending, 0, over)
+		st.dropped += over
+		slog.Warn("Notifier has too many undelivered alerts, dropping the oldest", "channel", channel, "dropped", over, "pending", len(st.pending))
+	}
+}
+
+// undeliveredError is returned by a send function that delivered part of a
+// batch, so only the alerts that failed are kept for the retry.
+type undeliveredError struct {
+	alerts []Alert
+	err    error
+}
+
+func (e *undeliveredError) Error() string { return e.err.Error() }
+func (e *undeliveredError) Unwrap() error { return e.err }
+
+// deliverPending sends the channel's pending alerts. On failure they are
+// queued again ahead of any that arrived meanwhile and the channel backs off.
+func deliverPending(channel string, st *notifierState) {
+	notifierMu.Lock()
+	if st.sending || len(st.pending) == 0 {
+		notifierMu.Unlock()
+		return
+	}
+	batch, send := st.pending, st.send
+	st.pending, st.sending = nil, true
+	notifierMu.Unlock()
+
+	err := send(batch)
+
+	notifierMu.Lock()
+	defer notifierMu.Unlock()
+	st.sending = false
+	if err == nil {
+		if st.failures > 0 {
+			slog.Info("Notifier recovered", "channel", channel, "failures", st.failures, "delivered", len(batch), "dropped", st.dropped)
+		}
+		st.failures, st.retryAt, st.dropped = 0, time.Time{}, 0
+		return
+	}
+	var undelivered *undeliveredError
+	if errors.As(err, &undelivered) {
+		batch = undelivered.alerts
+	}
+	newer := st.pending
+	st.pending = batch
+	st.queue(channel, newer)
+	backoff := notifierBackoffInitial << min(st.failures, 5)
+	if backoff > notifierBackoffMax {
+		backoff = notifierBackoffMax
+	}
+	st.failures++
+	st.retryAt = time.Now().Add(backoff)
+	slog.Error("Alert delivery failed, backing off", "channel", channel, "error", err, "failures", st.failures, "pending", len(st.pending), "retry_in", backoff)
+}
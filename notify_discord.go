@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+// Discord rejects messages that exceed these limits outright.
+const (
+	discordContentLimit     = 2000 // Characters in a message's content
+	discordDescriptionLimit = 4096 // Characters in an embed description
+	discordTitleLimit       = 256  // Characters in an embed title
+	discordEmbedTotalLimit  = 6000 // Characters across all embeds in a message
+	discordMaxEmbeds        = 10   // Embeds per message
+)
+
+// DiscordConfig posts alerts to a Discord channel webhook.
+type DiscordConfig struct {
+	WebhookURL     string `yaml:"webhook_url"`
+	WebhookURLFile string `yaml:"webhook_url_file"`
+}
+
+type discordEmbed struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Color       int    `json:"color"`
+	Timestamp   string `json:"timestamp"`
+}
+
+type discordPayload struct {
+	Content string         `json:"content"`
+	Embeds  []discordEmbed `json:"embeds"`
+}
+
+// discordColors maps alert kinds to embed colors.
+var discordColors = map[string]int{
+	"down":     0xE01E5A,
+	"recovery": 0x2EB67D,
+	"slow":     0xECB22E,
+	"degraded": 0xECB22E,
+	"self":     0xECB22E,
+}
+
+// sendDiscordAlert posts the alerts as one embed each. Discord caps a message
+// at 10 embeds and 6000 embed characters, so larger batches are split across
+// several messages.
+func sendDiscordAlert(cfg *Config, alerts []Alert) error {
+	var embeds []discordEmbed
+	for _, alert := range alerts {
+		title := fmt.Sprintf("%s %s (%s)", alert.Kind, alert.Result.Service.Name, alert.target())
+		if alert.Kind == "self" {
+			title = "self monitoring"
+		}
+		embeds = append(embeds, discordEmbed{
+			Title:       truncateChars(title, discordTitleLimit),
+			Description: "```\n" + truncateChars(alert.Message, discordDescriptionLimit-8) + "\n```",
+			Color:       discordColors[alert.Kind],
+			Timestamp:   alert.Time.Format(time.RFC3339),
+		})
+	}
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	content := truncateChars(fmt.Sprintf("InfraPulse: %d alert(s)", len(alerts)), discordContentLimit)
+	var errs []error
+	for len(embeds) > 0 {
+		n, size := 0, 0
+		for n < len(embeds) && n < discordMaxEmbeds {
+			embedSize := utf8.RuneCountInString(embeds[n].Title) + utf8.RuneCountInString(embeds[n].Description)
+			if n > 0 && size+embedSize > discordEmbedTotalLimit {
+				break
+			}
+			size += embedSize
+			n++
+		}
+		if err := postDiscord(client, cfg.Discord.WebhookURL, discordPayload{Content: content, Embeds: embeds[:n]}); err != nil {
+			errs = append(errs, err)
+		}
+		embeds = embeds[n:]
+	}
+	return errors.Join(errs...)
+}
+
+func postDiscord(client *http.Client, webhookURL string, payload discordPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode Discord payload: %w", err)
+	}
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("rejected with status %d: %s", resp.StatusCode, reply)
+	}
+	slog.Info("Discord alert sent successfully.", "embeds", len(payload.Embeds))
+	return nil
+}
+
+// truncateChars shortens s to at most n characters, marking the cut with an
+// ellipsis. Unlike truncate it counts runes, as Discord's limits do.
+func truncateChars(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSendDiscordAlertBatching(t *testing.T) {
+	alerts := func(n, size int) []Alert {
+		var alerts []Alert
+		for i := range n {
+			alerts = append(alerts, Alert{Kind: "down", Result: CheckResult{Service: Service{Name: "svc", Host: "10.0.0.1", Port: 1000 + i}}, Message: strings.Repeat("x", size)})
+		}
+		return alerts
+	}
+	tests := []struct {
+		name   string
+		alerts []Alert
+		want   []int // Embeds per message
+	}{
+		{"one message", alerts(3, 100), []int{3}},
+		{"ten embeds per message", alerts(25, 100), []int{10, 10, 5}},
+		{"6000 characters per message", alerts(5, 2500), []int{2, 2, 1}},
+		{"oversized description truncated", alerts(2, 10000), []int{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var payload discordPayload
+				if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+					t.Errorf("decoding payload: %v", err)
+				}
+				size := 0
+				for _, embed := range payload.Embeds {
+					if n := utf8.RuneCountInString(embed.Description); n > discordDescriptionLimit {
+						t.Errorf("embed description is %d characters, over %d", n, discordDescriptionLimit)
+					}
+					size += utf8.RuneCountInString(embed.Title) + utf8.RuneCountInString(embed.Description)
+				}
+				if size > discordEmbedTotalLimit && len(payload.Embeds) > 1 {
+					t.Errorf("message embeds total %d characters, over %d", size, discordEmbedTotalLimit)
+				}
+				got = append(got, len(payload.Embeds))
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			cfg := &Config{Discord: DiscordConfig{WebhookURL: server.URL}}
+			if err := sendDiscordAlert(cfg, tt.alerts); err != nil {
+				t.Fatalf("sendDiscordAlert: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("messages = %v embeds, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("messages = %v embeds, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestTruncateChars(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly", 7, "exactly"},
+		{"truncated", 5, "trun…"},
+		{"héllo wörld", 6, "héllo…"},
+	}
+	for _, tt := range tests {
+		if got := truncateChars(tt.in, tt.n); got != tt.want {
+			t.Errorf("truncateChars(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
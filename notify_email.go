@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Well-known submission ports: 465 speaks TLS from the first byte, 587 starts
+// in plain text and must be upgraded with STARTTLS before credentials are
+// sent.
+const (
+	smtpImplicitTLSPort = 465
+	smtpSubmissionPort  = 587
+)
+
+// smtpTimeout bounds a whole email delivery, so an unresponsive mail server
+// can't hold up the rest of the alert dispatch.
+const smtpTimeout = 30 * time.Second
+
+// emailData is what email_subject_template and email_body_template are
+// rendered with. Subject and Body hold the built-in text, so a template can
+// wrap it rather than replace it.
+type emailData struct {
+	Kind     string // "alert" or "recovery"
+	Time     time.Time
+	Group    string
+	Alerts   []alertRecord
+	Down     []alertRecord // Alert emails: every alert that isn't degraded
+	Degraded []alertRecord // Alert emails: services up but over their latency threshold
+	Subject  string
+	Body     string
+}
+
+func newEmailData(kind string, alerts []Alert) emailData {
+	data := emailData{Kind: kind, Time: time.Now(), Alerts: make([]alertRecord, len(alerts))}
+	for i, alert := range alerts {
+		record := newAlertRecord(alert)
+		data.Alerts[i] = record
+		switch {
+		case kind != "alert":
+		case alert.Kind == "degraded":
+			data.Degraded = append(data.Degraded, record)
+		default:
+			data.Down = append(data.Down, record)
+		}
+	}
+	if len(alerts) > 0 {
+		data.Group = alerts[0].Result.Service.Group
+	}
+	return data
+}
+
+func parseEmailTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(webhookFuncs).Option("missingkey=error").Parse(text)
+}
+
+// renderEmail returns the subject and body of an email, rendered from the
+// configured templates. Where a template isn't set, or fails to render, the
+// built-in text in data is used, so a broken template never stops an alert
+// from going out.
+func renderEmail(cfg *Config, data emailData) (string, string) {
+	subject, body := data.Subject, data.Body
+	if cfg.EmailSubjectTemplate != "" {
+		if text, ok := executeEmailTemplate("email_subject_template", cfg.EmailSubjectTemplate, data); ok {
+			// A subject is one header line.
+			subject = strings.Join(strings.Fields(text), " ")
+		}
+	}
+	if cfg.EmailBodyTemplate != "" {
+		if text, ok := executeEmailTemplate("email_body_template", cfg.EmailBodyTemplate, data); ok {
+			body = text
+		}
+	}
+	return subject, body
+}
+
+func executeEmailTemplate(name, text string, data emailData) (string, bool) {
+	tmpl, err := parseEmailTemplate(name, text)
+	if err == nil {
+		var out bytes.Buffer
+		if err = tmpl.Execute(&out, data); err == nil {
+			return out.String(), true
+		}
+	}
+	slog.Warn("Failed to render email template, using the built-in text", "template", name, "error", err)
+	return "", false
+}
+
+// sendEmail delivers one email to every alert recipient. Port 465 connects
+// with TLS from the start; any other port is upgraded with STARTTLS when the
+// server offers it, which port 587 must before credentials are sent. Without
+// a username no authentication is attempted, for relays that accept mail
+// from trusted hosts.
+func sendEmail(cfg *Config, subjectLine, body string) error {
+	if cfg.AlertRecipient == "" {
+		return errors.New("alert_recipient is not set in config.yaml")
+	}
+
+	from := cfg.SMTP.sender()
+	to := strings.Split(cfg.AlertRecipient, ",")
+	for i, email := range to {
+		to[i] = strings.TrimSpace(email)
+	}
+	host := cfg.SMTP.Host
+	addr := net.JoinHostPort(host, strconv.Itoa(cfg.SMTP.Port))
+	tlsConfig := &tls.Config{ServerName: host, InsecureSkipVerify: cfg.SMTP.TLSSkipVerify}
+
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	var err error
+	if cfg.SMTP.Port == smtpImplicitTLSPort {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("%s: greeting: %w", addr, err)
+	}
+	defer client.Close()
+
+	if cfg.SMTP.Port != smtpImplicitTLSPort {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("%s: STARTTLS: %w", addr, err)
+			}
+		} else if cfg.SMTP.Port == smtpSubmissionPort && cfg.SMTP.Username != "" {
+			return fmt.Errorf("%s does not offer STARTTLS, refusing to send credentials in plain text", addr)
+		}
+	}
+	if ok, _ := client.Extension("AUTH"); ok && cfg.SMTP.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.SMTP.Username, cfg.SMTP.Password, host)); err != nil {
+			return fmt.Errorf("%s: AUTH: %w", addr, err)
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return fmt.Errorf("%s: MAIL FROM: %w", addr, err)
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("%s: RCPT TO %s: %w", addr, recipient, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("%s: DATA: %w", addr, err)
+	}
+	if _, err := w.Write([]byte("Subject: " + subjectLine + "\n" + body)); err != nil {
+		return fmt.Errorf("%s: DATA: %w", addr, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("%s: DATA: %w", addr, err)
+	}
+	client.Quit()
+
+	slog.Info("Email alert sent successfully.")
+	return nil
+}
+
+// sender is the address alerts are sent from: from if set, otherwise the
+// username, otherwise infrapulse@ this host's name.
+func (c SMTPConfig) sender() string {
+	if c.From != "" {
+		return c.From
+	}
+	if c.Username != "" {
+		return c.Username
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	return "infrapulse@" + hostname
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// FileNotifierConfig appends alerts as JSON lines to a spool file, for
+// environments where an external forwarder ships them onward.
+type FileNotifierConfig struct {
+	Path       string `yaml:"path"`
+	MaxSizeMB  int    `yaml:"max_size_mb"` // Rotate once the file reaches this size, default 10
+	MaxBackups int    `yaml:"max_backups"` // Rotated files to keep, default 3
+}
+
+// alertRecord is the JSON shape of an alert for machine consumers: the spool
+// file, Kafka and webhook templates.
+type alertRecord struct {
+	Time     time.Time         `json:"time"`
+	Kind     string            `json:"kind"`
+	Service  string            `json:"service"`
+	Host     string            `json:"host"`
+	Port     int               `json:"port"`
+	Check    string            `json:"check"`
+	Group    string            `json:"group,omitempty"`
+	Status   Status            `json:"status"`
+	Error    string            `json:"error,omitempty"`
+	DedupKey string            `json:"dedup_key"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Message  string            `json:"message"`
+}
+
+func newAlertRecord(alert Alert) alertRecord {
+	record := alertRecord{
+		Time:     alert.Time,
+		Kind:     alert.Kind,
+		Service:  alert.Result.Service.Name,
+		Host:     alert.Result.Service.Host,
+		Port:     alert.Result.Service.Port,
+		Check:    alert.Result.Service.checkType(),
+		Group:    alert.Result.Service.Group,
+		Status:   alert.Result.Status,
+		DedupKey: alert.Result.Service.DedupKey,
+		Labels:   alert.Result.Service.Labels.toMap(),
+		Message:  alert.Message,
+	}
+	if alert.Result.Error != nil {
+		record.Error = alert.Result.Error.Error()
+	}
+	return record
+}
+
+func writeAlertFile(cfg FileNotifierConfig, alerts []Alert) error {
+	for _, alert := range alerts {
+		line, err := json.Marshal(newAlertRecord(alert))
+		if err != nil {
+			slog.Error("Failed to encode alert for file", "error", err)
+			continue
+		}
+		if err := appendLine(cfg, append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to write %s: %w", cfg.Path, err)
+		}
+	}
+	return nil
+}
+
+// appendLine writes line with a single O_APPEND write so concurrent readers
+// and forwarders never see a partial record, rotating the file first if the
+// line would push it past the size limit.
+func appendLine(cfg FileNotifierConfig, line []byte) error {
+	maxSize := int64(cfg.MaxSizeMB) << 20
+	if maxSize <= 0 {
+		maxSize = 10 << 20
+	}
+	if info, err := os.Stat(cfg.Path); err == nil && info.Size()+int64(len(line)) > maxSize {
+		if err := rotateFile(cfg.Path, cfg.MaxBackups); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(cfg.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// rotateFile shifts path to path.1, path.1 to path.2 and so on, dropping
+// anything beyond the backup limit.
+func rotateFile(path string, backups int) error {
+	if backups <= 0 {
+		backups = 3
+	}
+	os.Remove(fmt.Sprintf("%s.%d", path, backups))
+	for i := backups - 1; i >= 1; i-- {
+		old := fmt.Sprintf("%s.%d", path, i)
+		if _, err := os.Stat(old); err == nil {
+			if err := os.Rename(old, fmt.Sprintf("%s.%d", path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Rename(path, path+".1")
+}
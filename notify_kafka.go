@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// KafkaNotifierConfig produces each alert as a JSON message to a topic, keyed
+// by the service's dedup key so all alerts for a service land on the same
+// partition in order.
+type KafkaNotifierConfig struct {
+	Brokers []string        `yaml:"brokers"`
+	Topic   string          `yaml:"topic"`
+	TLS     bool            `yaml:"tls"`
+	SASL    KafkaSASLConfig `yaml:"sasl"`
+	Retries int             `yaml:"retries"` // Delivery attempts per batch, default 3
+}
+
+type KafkaSASLConfig struct {
+	Mechanism    string `yaml:"mechanism"` // plain, scram-sha-256 or scram-sha-512
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+func (c KafkaSASLConfig) mechanism() (sasl.Mechanism, error) {
+	switch c.Mechanism {
+	case "":
+		return nil, nil
+	case "plain":
+		return plain.Mechanism{Username: c.Username, Password: c.Password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, c.Username, c.Password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, c.Username, c.Password)
+	default:
+		return nil, fmt.Errorf("unsupported kafka sasl mechanism %q", c.Mechanism)
+	}
+}
+
+func sendAlertKafka(cfg KafkaNotifierConfig, alerts []Alert) error {
+	mechanism, err := cfg.SASL.mechanism()
+	if err != nil {
+		return fmt.Errorf("failed to configure Kafka producer: %w", err)
+	}
+	transport := &kafka.Transport{SASL: mechanism}
+	if cfg.TLS {
+		transport.TLS = &tls.Config{}
+	}
+	attempts := cfg.Retries
+	if attempts <= 0 {
+		attempts = 3
+	}
+
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Topic:        cfg.Topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		MaxAttempts:  attempts,
+		Transport:    transport,
+	}
+	defer writer.Close()
+
+	var messages []kafka.Message
+	for _, alert := range alerts {
+		value, err := json.Marshal(newAlertRecord(alert))
+		if err != nil {
+			slog.Error("Failed to encode alert for Kafka", "error", err)
+			continue
+		}
+		messages = append(messages, kafka.Message{Key: []byte(alert.Result.Service.DedupKey), Value: value})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := writer.WriteMessages(ctx, messages...); err != nil {
+		return fmt.Errorf("failed to produce %d alert(s) to topic %s after %d attempts: %w", len(messages), cfg.Topic, attempts, err)
+	}
+	slog.Info("Alerts produced to Kafka", "topic", cfg.Topic, "alerts", len(messages))
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// errPagerDutyRejected marks an event PagerDuty refused as invalid, which
+// sending it again would not change.
+var errPagerDutyRejected = errors.New("rejected by PagerDuty")
+
+// PagerDutyConfig sends alerts to a PagerDuty service through the Events API
+// v2, triggering an incident when a service goes down and resolving it on
+// recovery.
+type PagerDutyConfig struct {
+	RoutingKey     string `yaml:"routing_key"`
+	RoutingKeyFile string `yaml:"routing_key_file"`
+	Severity       string `yaml:"severity"` // critical (default), error, warning or info
+	URL            string `yaml:"url"`      // Overrides the Events API endpoint
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Client      string            `json:"client"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	Timestamp     string         `json:"timestamp"`
+	Component     string         `json:"component"`
+	CustomDetails map[string]any `json:"custom_details"`
+}
+
+// sendPagerDutyAlert sends a trigger event for every down alert and a resolve
+// event for every recovery. Both carry the service's dedup key, which is how
+// PagerDuty matches a recovery to the incident it resolves. Slow-check,
+// degraded and self-monitoring alerts are not paged; a service that is up
+// but slow is not an incident. Events that fail are returned in an
+// undeliveredError to be retried with the channel's pending alerts, except
+// rejected ones, which are only logged.
+func sendPagerDutyAlert(cfg PagerDutyConfig, alerts []Alert) error {
+	url := cfg.URL
+	if url == "" {
+		url = pagerDutyEventsURL
+	}
+	severity := cfg.Severity
+	if severity == "" {
+		severity = "critical"
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	var errs []error
+	var retry []Alert
+	for _, alert := range expandHostAlerts(alerts) {
+		event := pagerDutyEvent{RoutingKey: cfg.RoutingKey, DedupKey: alert.Result.Service.DedupKey, Client: "InfraPulse"}
+		switch alert.Kind {
+		case "down":
+			event.EventAction = "trigger"
+			summary := alert.Result.Service.Name + " (" + alert.Result.Service.ID() + ") is DOWN"
+			if alert.Result.Error != nil {
+				summary += ": " + alert.Result.Error.Error()
+			}
+			event.Payload = &pagerDutyPayload{
+				Summary:   truncateChars(summary, 1024),
+				Source:    alert.Result.Service.Host,
+				Severity:  severity,
+				Timestamp: alert.Time.Format(time.RFC3339),
+				Component: alert.Result.Service.Name,
+				CustomDetails: map[string]any{
+					"id":      alert.Result.Service.ID(),
+					"type":    alert.Result.Service.checkType(),
+					"message": alert.Message,
+				},
+			}
+			if labels := alert.Result.Service.Labels.toMap(); labels != nil {
+				event.Payload.CustomDetails["labels"] = labels
+			}
+		case "recovery":
+			event.EventAction = "resolve"
+		default:
+			continue
+		}
+		if err := postPagerDuty(client, url, event); err != nil {
+			if errors.Is(err, errPagerDutyRejected) {
+				slog.Error("PagerDuty event rejected, not retrying", "error", err)
+				continue
+			}
+			errs = append(errs, err)
+			retry = append(retry, alert)
+		}
+	}
+	if len(errs) > 0 {
+		return &undeliveredError{alerts: retry, err: errors.Join(errs...)}
+	}
+	return nil
+}
+
+// expandHostAlerts splits host-down alerts back into one alert per affected
+// service, so each gets its own incident and is resolved by its own recovery.
+func expandHostAlerts(alerts []Alert) []Alert {
+	var expanded []Alert
+	for _, alert := range alerts {
+		if len(alert.Affected) == 0 {
+			expanded = append(expanded, alert)
+			continue
+		}
+		for _, result := range alert.Affected {
+			expanded = append(expanded, Alert{Kind: alert.Kind, Result: result, Message: alert.Message, Time: alert.Time})
+		}
+	}
+	return expanded
+}
+
+// postPagerDuty delivers one event. It is not retried here: a rate limited
+// (429) or failed (5xx) event waits in the channel's pending alerts for the
+// notifier backoff, so a PagerDuty outage never holds up a check cycle. A 4xx
+// means the event itself is invalid and wraps errPagerDutyRejected.
+func postPagerDuty(client *http.Client, url string, event pagerDutyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode PagerDuty event: %w", err)
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%s event for %s failed: %w", event.EventAction, event.DedupKey, err)
+	}
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	resp.Body.Close()
+
+	switch status := resp.StatusCode; {
+	case status >= 200 && status <= 299:
+		slog.Info("PagerDuty event sent successfully.", "action", event.EventAction, "dedup_key", event.DedupKey)
+		return nil
+	case status != http.StatusTooManyRequests && status < 500:
+		return fmt.Errorf("%s event for %s %w with status %d: %s", event.EventAction, event.DedupKey, errPagerDutyRejected, status, strings.TrimSpace(string(reply)))
+	default:
+		return fmt.Errorf("%s event for %s failed with status %d", event.EventAction, event.DedupKey, status)
+	}
+}
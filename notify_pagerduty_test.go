@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestSendPagerDutyAlertKeepsFailedEvents(t *testing.T) {
+	statuses := map[string]int{"ok": http.StatusAccepted, "flaky": http.StatusServiceUnavailable, "limited": http.StatusTooManyRequests, "invalid": http.StatusBadRequest}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var event pagerDutyEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("decoding event: %v", err)
+		}
+		w.WriteHeader(statuses[event.DedupKey])
+	}))
+	defer server.Close()
+
+	var alerts []Alert
+	for _, key := range []string{"ok", "flaky", "limited", "invalid"} {
+		alerts = append(alerts, Alert{Kind: "down", Result: CheckResult{Service: Service{Name: key, Host: "example.com", DedupKey: key}}})
+	}
+	alerts = append(alerts, Alert{Kind: "slow", Result: CheckResult{Service: Service{Name: "slow", DedupKey: "slow"}}})
+
+	err := sendPagerDutyAlert(PagerDutyConfig{RoutingKey: "key", URL: server.URL}, alerts)
+	var undelivered *undeliveredError
+	if !errors.As(err, &undelivered) {
+		t.Fatalf("sendPagerDutyAlert = %v, want an undeliveredError", err)
+	}
+	var retry []string
+	for _, alert := range undelivered.alerts {
+		retry = append(retry, alert.Result.Service.DedupKey)
+	}
+	if want := []string{"flaky", "limited"}; !slices.Equal(retry, want) {
+		t.Errorf("alerts kept for retry = %q, want %q", retry, want)
+	}
+	if requests != 4 {
+		t.Errorf("%d requests, want 4: one per paged alert and no retries", requests)
+	}
+}
+
+func TestDeliverPendingRequeuesOnlyUndelivered(t *testing.T) {
+	sent := Alert{Kind: "down", Result: CheckResult{Service: Service{Name: "sent"}}}
+	failed := Alert{Kind: "down", Result: CheckResult{Service: Service{Name: "failed"}}}
+	st := &notifierState{
+		pending: []Alert{sent, failed},
+		send: func([]Alert) error {
+			return &undeliveredError{alerts: []Alert{failed}, err: errors.New("status 503")}
+		},
+	}
+	deliverPending("pagerduty", st)
+	if len(st.pending) != 1 || st.pending[0].Result.Service.Name != "failed" {
+		t.Errorf("pending = %v, want only the failed alert", st.pending)
+	}
+	if st.failures != 1 || st.retryAt.IsZero() {
+		t.Errorf("failures = %d, retryAt = %v, want the channel backing off", st.failures, st.retryAt)
+	}
+}
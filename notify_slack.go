@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// SlackConfig posts alerts to a Slack incoming webhook.
+type SlackConfig struct {
+	WebhookURL     string `yaml:"webhook_url"`
+	WebhookURLFile string `yaml:"webhook_url_file"`
+}
+
+type slackAttachment struct {
+	Color    string `json:"color"`
+	Title    string `json:"title"`
+	Text     string `json:"text"`
+	Fallback string `json:"fallback"`
+	Ts       int64  `json:"ts"`
+}
+
+type slackPayload struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+// slackColors maps alert kinds to attachment colors.
+var slackColors = map[string]string{
+	"down":     "danger",
+	"recovery": "good",
+	"slow":     "warning",
+	"degraded": "warning",
+	"self":     "warning",
+}
+
+func sendSlackAlert(cfg *Config, alerts []Alert) error {
+	payload := slackPayload{Text: fmt.Sprintf("InfraPulse: %d alert(s)", len(alerts))}
+	for _, alert := range alerts {
+		title := fmt.Sprintf("%s %s (%s)", alert.Kind, alert.Result.Service.Name, alert.target())
+		if alert.Kind == "self" {
+			title = "self monitoring"
+		}
+		payload.Attachments = append(payload.Attachments, slackAttachment{
+			Color:    slackColors[alert.Kind],
+			Title:    title,
+			Text:     "```" + alert.Message + "```",
+			Fallback: alert.Message,
+			Ts:       alert.Time.Unix(),
+		})
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode Slack payload: %w", err)
+	}
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Post(cfg.Slack.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("rejected with status %d: %s", resp.StatusCode, reply)
+	}
+	slog.Info("Slack alert sent successfully.")
+	return nil
+}
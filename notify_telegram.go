@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	telegramAPIURL       = "https://api.telegram.org"
+	telegramMessageLimit = 4096 // Characters in a message's text
+)
+
+// TelegramConfig sends alerts to a Telegram chat through a bot.
+type TelegramConfig struct {
+	BotToken     string `yaml:"bot_token"`
+	BotTokenFile string `yaml:"bot_token_file"`
+	ChatID       string `yaml:"chat_id"` // Numeric chat ID, or @channelname for public channels
+	APIURL       string `yaml:"api_url"` // Overrides the Bot API endpoint
+}
+
+type telegramMessage struct {
+	ChatID    string `json:"chat_id"`
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode"`
+}
+
+type telegramReply struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+// telegramIcons marks alert kinds at the start of each alert's heading.
+var telegramIcons = map[string]string{
+	"down":     "🔴",
+	"recovery": "🟢",
+	"slow":     "🟡",
+	"degraded": "🟡",
+	"self":     "🟡",
+}
+
+// sendTelegramAlert sends the alerts with the Bot API's sendMessage, each as
+// a bold heading over its text in a code block. Telegram caps a message at
+// 4096 characters, so alerts are packed into as few messages as fit and an
+// alert too long for a message of its own is truncated.
+func sendTelegramAlert(cfg TelegramConfig, alerts []Alert) error {
+	apiURL := cfg.APIURL
+	if apiURL == "" {
+		apiURL = telegramAPIURL
+	}
+	endpoint := strings.TrimSuffix(apiURL, "/") + "/bot" + cfg.BotToken + "/sendMessage"
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	var errs []error
+	for _, text := range telegramMessages(alerts) {
+		if err := postTelegram(client, endpoint, telegramMessage{ChatID: cfg.ChatID, Text: text, ParseMode: "MarkdownV2"}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// telegramMessages formats the alerts as MarkdownV2 and splits them into
+// messages within Telegram's length limit.
+func telegramMessages(alerts []Alert) []string {
+	var messages []string
+	var current strings.Builder
+	for _, alert := range alerts {
+		title := fmt.Sprintf("%s %s (%s)", alert.Kind, alert.Result.Service.Name, alert.target())
+		if alert.Kind == "self" {
+			title = "self monitoring"
+		}
+		heading := telegramIcons[alert.Kind] + " *" + escapeTelegramMarkdown(title) + "*\n"
+		const fence = "```\n"
+		// Leave room for the heading, the fences and the blank line between
+		// alerts; escaping can at most double the body's length.
+		room := (telegramMessageLimit - utf8.RuneCountInString(heading) - 2*len(fence) - 2) / 2
+		block := heading + fence + escapeTelegramCode(truncateChars(alert.Message, room)) + "\n" + fence
+
+		if current.Len() > 0 && utf8.RuneCountInString(current.String())+2+utf8.RuneCountInString(block) > telegramMessageLimit {
+			messages = append(messages, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n")
+		}
+		current.WriteString(block)
+	}
+	if current.Len() > 0 {
+		messages = append(messages, current.String())
+	}
+	return messages
+}
+
+// escapeTelegramMarkdown escapes the characters MarkdownV2 reserves outside
+// code.
+func escapeTelegramMarkdown(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("_*[]()~`>#+-=|{}.!\\", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// escapeTelegramCode escapes the characters MarkdownV2 reserves inside a code
+// block.
+func escapeTelegramCode(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(s)
+}
+
+func postTelegram(client *http.Client, endpoint string, message telegramMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode Telegram message: %w", err)
+	}
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The request URL holds the bot token, which must not end up in logs.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("%s request failed: %w", urlErr.Op, urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	var reply telegramReply
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err := json.Unmarshal(data, &reply); err != nil || !reply.OK {
+		if reply.Description == "" {
+			reply.Description = strings.TrimSpace(string(data))
+		}
+		return fmt.Errorf("rejected with status %d: %s", resp.StatusCode, reply.Description)
+	}
+	slog.Info("Telegram alert sent successfully.", "chat_id", message.ChatID)
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTelegramMessages(t *testing.T) {
+	alert := func(name, message string) Alert {
+		return Alert{Kind: "down", Result: CheckResult{Service: Service{Name: name, Host: "db.example.com", Port: 5432}}, Message: message}
+	}
+	tests := []struct {
+		name         string
+		alerts       []Alert
+		wantMessages int
+	}{
+		{"none", nil, 0},
+		{"small alerts share a message", []Alert{alert("a", "down"), alert("b", "down"), alert("c", "down")}, 1},
+		{"large alerts are split", []Alert{alert("a", strings.Repeat("x", 3000)), alert("b", strings.Repeat("y", 3000))}, 2},
+		{"oversized alert is truncated", []Alert{alert("a", strings.Repeat("z", 10000))}, 1},
+		{"escaping counts against the limit", []Alert{alert("a", strings.Repeat("`", 5000)), alert("b", "down")}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := telegramMessages(tt.alerts)
+			if len(messages) != tt.wantMessages {
+				t.Fatalf("%d messages, want %d", len(messages), tt.wantMessages)
+			}
+			headings := 0
+			for i, message := range messages {
+				if n := utf8.RuneCountInString(message); n > telegramMessageLimit {
+					t.Errorf("message %d is %d characters, over the limit of %d", i, n, telegramMessageLimit)
+				}
+				if strings.Count(message, "```")%2 != 0 {
+					t.Errorf("message %d has an unclosed code block", i)
+				}
+				headings += strings.Count(message, "🔴 *down ")
+			}
+			if headings != len(tt.alerts) {
+				t.Errorf("%d alerts in the messages, want %d", headings, len(tt.alerts))
+			}
+		})
+	}
+}
+
+func TestEscapeTelegramMarkdown(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"down db (db.example.com:5432)", `down db \(db\.example\.com:5432\)`},
+		{"web-1_a*b", `web\-1\_a\*b`},
+		{"plain", "plain"},
+	}
+	for _, tt := range tests {
+		if got := escapeTelegramMarkdown(tt.in); got != tt.want {
+			t.Errorf("escapeTelegramMarkdown(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if got := escapeTelegramCode("a`b\\c"); got != "a\\`b\\\\c" {
+		t.Errorf("escapeTelegramCode = %q", got)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func testAlert(name string) Alert {
+	return Alert{Kind: "down", Result: CheckResult{Service: Service{Name: name}}}
+}
+
+func alertNames(alerts []Alert) []string {
+	names := make([]string, len(alerts))
+	for i, alert := range alerts {
+		names[i] = alert.Result.Service.Name
+	}
+	return names
+}
+
+func TestNotifierStateQueueDropsOldest(t *testing.T) {
+	st := &notifierState{}
+	for i := range maxPendingAlerts {
+		st.queue("test", []Alert{testAlert(string(rune('a' + i%26)))})
+	}
+	st.queue("test", []Alert{testAlert("new1"), testAlert("new2")})
+	if len(st.pending) != maxPendingAlerts || st.dropped != 2 {
+		t.Fatalf("pending = %d, dropped = %d, want %d and 2", len(st.pending), st.dropped, maxPendingAlerts)
+	}
+	if first, last := st.pending[0].Result.Service.Name, st.pending[len(st.pending)-1].Result.Service.Name; first != "c" || last != "new2" {
+		t.Errorf("pending runs from %s to %s, want c to new2", first, last)
+	}
+}
+
+func TestDeliverPendingBackoff(t *testing.T) {
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 30 * time.Minute, 30 * time.Minute}
+	st := &notifierState{pending: []Alert{testAlert("db")}, send: func([]Alert) error { return errors.New("connection refused") }}
+	for i, backoff := range want {
+		before := time.Now()
+		deliverPending("test", st)
+		if st.failures != i+1 {
+			t.Fatalf("failures = %d after %d failed sends", st.failures, i+1)
+		}
+		if wait := st.retryAt.Sub(before); wait < backoff || wait > backoff+time.Second {
+			t.Errorf("failure %d: retrying in %s, want %s", i+1, wait, backoff)
+		}
+		if len(st.pending) != 1 {
+			t.Fatalf("failure %d: %d alerts pending, want the failed one kept", i+1, len(st.pending))
+		}
+	}
+
+	var sent []Alert
+	st.send = func(alerts []Alert) error {
+		sent = alerts
+		return nil
+	}
+	deliverPending("test", st)
+	if len(sent) != 1 || len(st.pending) != 0 || st.failures != 0 || !st.retryAt.IsZero() {
+		t.Errorf("after a successful send: sent %d, pending %d, failures %d, retryAt %v, want 1, 0, 0 and zero", len(sent), len(st.pending), st.failures, st.retryAt)
+	}
+}
+
+func TestDeliverPendingKeepsOrder(t *testing.T) {
+	st := &notifierState{pending: []Alert{testAlert("first"), testAlert("second")}}
+	st.send = func([]Alert) error {
+		// An alert that comes due while the send is in progress.
+		notifierMu.Lock()
+		st.queue("test", []Alert{testAlert("third")})
+		notifierMu.Unlock()
+		return errors.New("timeout")
+	}
+	deliverPending("test", st)
+	got := alertNames(st.pending)
+	if want := []string{"first", "second", "third"}; !slices.Equal(got, want) {
+		t.Errorf("pending = %q, want %q", got, want)
+	}
+}
+
+func TestNotifyChannelWhileBackingOff(t *testing.T) {
+	const channel = "test-backoff"
+	t.Cleanup(func() {
+		notifierMu.Lock()
+		delete(notifierStates, channel)
+		notifierMu.Unlock()
+	})
+	sends := 0
+	fail := func([]Alert) error {
+		sends++
+		return errors.New("503")
+	}
+	notifyChannel(channel, []Alert{testAlert("db")}, fail)
+	notifyChannel(channel, []Alert{testAlert("web")}, fail)
+	if sends != 1 {
+		t.Errorf("%d sends, want 1: the second alert should wait for the backoff", sends)
+	}
+	notifierMu.Lock()
+	got := alertNames(notifierStates[channel].pending)
+	notifierMu.Unlock()
+	if want := []string{"db", "web"}; !slices.Equal(got, want) {
+		t.Errorf("pending = %q, want %q", got, want)
+	}
+}
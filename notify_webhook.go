@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"text/template"
+	"time"
+)
+
+// defaultWebhookTemplate posts the alerts as the same JSON records the file
+// and Kafka notifiers write.
+const defaultWebhookTemplate = `{"alerts": {{json .Alerts}}}`
+
+// WebhookConfig sends alerts to an arbitrary HTTP endpoint, with the request
+// body rendered from a user-supplied Go template so any chat or incident
+// tool can be targeted without code changes.
+type WebhookConfig struct {
+	URL      string            `yaml:"url"`
+	URLFile  string            `yaml:"url_file"`
+	Method   string            `yaml:"method"`    // Default POST
+	Headers  map[string]string `yaml:"headers"`   // Content-Type defaults to application/json
+	Template string            `yaml:"template"`  // Go text/template for the body
+	PerAlert bool              `yaml:"per_alert"` // One request per alert instead of per batch
+}
+
+// webhookData is what a webhook template is rendered with. Alerts holds the
+// whole batch, or just the one alert in per_alert mode, where that alert's
+// fields are also available directly (.Service, .Status, ...).
+type webhookData struct {
+	alertRecord
+	Alerts []alertRecord
+}
+
+var webhookFuncs = template.FuncMap{
+	// json renders a value as a JSON literal, quoting and escaping strings.
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func parseWebhookTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultWebhookTemplate
+	}
+	return template.New("webhook").Funcs(webhookFuncs).Option("missingkey=error").Parse(text)
+}
+
+func sendWebhookAlert(cfg WebhookConfig, alerts []Alert) error {
+	tmpl, err := parseWebhookTemplate(cfg.Template)
+	if err != nil {
+		return fmt.Errorf("failed to parse webhook template: %w", err)
+	}
+
+	records := make([]alertRecord, len(alerts))
+	for i, alert := range alerts {
+		records[i] = newAlertRecord(alert)
+	}
+	var batches []webhookData
+	if cfg.PerAlert {
+		for _, record := range records {
+			batches = append(batches, webhookData{alertRecord: record, Alerts: []alertRecord{record}})
+		}
+	} else {
+		batches = append(batches, webhookData{Alerts: records})
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	var errs []error
+	for _, data := range batches {
+		var body bytes.Buffer
+		if err := tmpl.Execute(&body, data); err != nil {
+			errs = append(errs, fmt.Errorf("failed to render webhook template: %w", err))
+			continue
+		}
+		if err := postWebhook(client, cfg, body.Bytes()); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		slog.Info("Webhook alert sent successfully.", "alerts", len(data.Alerts))
+	}
+	return errors.Join(errs...)
+}
+
+func postWebhook(client *http.Client, cfg WebhookConfig, body []byte) error {
+	method := cfg.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequest(method, cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range cfg.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, reply)
+	}
+	return nil
+}
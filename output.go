@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// jsonOutput switches result output to one JSON object per line on stdout,
+// set by the -json flag. Human-readable messages then go to stderr.
+var jsonOutput bool
+
+// quietOutput limits console output to DOWN results, set by the -quiet flag.
+// Banners, summaries and notifier progress are left out.
+var quietOutput bool
+
+// progress prints a status message with print, such as color.Cyan, unless
+// -quiet is set.
+func progress(print func(format string, a ...interface{}), format string, args ...interface{}) {
+	if !quietOutput {
+		print(format, args...)
+	}
+}
+
+// jsonResult is the JSON shape of a check result.
+type jsonResult struct {
+	Time      time.Time `json:"time"`
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Host      string    `json:"host"`
+	Port      int       `json:"port"`
+	Check     string    `json:"check"`
+	Status    Status    `json:"status"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+}
+
+func printResultJSON(result CheckResult) {
+	record := jsonResult{
+		Time:      result.Time,
+		ID:        result.Service.ID(),
+		Name:      result.Service.Name,
+		Host:      result.Service.Host,
+		Port:      result.Service.Port,
+		Check:     result.Service.checkType(),
+		Status:    result.Status,
+		LatencyMs: milliseconds(result.Latency),
+	}
+	if result.Error != nil {
+		record.Error = result.Error.Error()
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		slog.Error("Failed to encode result", "error", err)
+		return
+	}
+	fmt.Fprintln(os.Stdout, string(line))
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	probing "github.com/prometheus-community/pro-bing"
+	"gopkg.in/yaml.v3"
+)
+
+// PingPolicy decides how many ping replies make a host UP: "any" (the
+// default), "all", "majority", or a minimum percentage such as "80%".
+type PingPolicy string
+
+func (p *PingPolicy) UnmarshalYAML(node *yaml.Node) error {
+	var value string
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	policy := PingPolicy(strings.ToLower(strings.TrimSpace(value)))
+	if _, err := policy.percent(); err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*p = policy
+	return nil
+}
+
+func (p PingPolicy) String() string {
+	if p == "" {
+		return "any"
+	}
+	return string(p)
+}
+
+// percent returns the share of replies the policy requires, where "any" is
+// represented as 0 (at least one reply).
+func (p PingPolicy) percent() (float64, error) {
+	switch p {
+	case "", "any":
+		return 0, nil
+	case "all":
+		return 100, nil
+	case "majority":
+		return 50, nil
+	}
+	if s, ok := strings.CutSuffix(string(p), "%"); ok {
+		percent, err := strconv.ParseFloat(s, 64)
+		if err == nil && percent > 0 && percent <= 100 {
+			return percent, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid ping_success_policy %q: use any, all, majority or a percentage like 80%%", string(p))
+}
+
+// satisfied reports whether recv replies out of sent packets meets the policy.
+func (p PingPolicy) satisfied(sent, recv int) bool {
+	if recv == 0 || sent == 0 {
+		return false
+	}
+	percent, _ := p.percent()
+	switch {
+	case percent == 0:
+		return true
+	case p == "majority":
+		return recv*2 > sent
+	default:
+		return float64(recv)*100 >= percent*float64(sent)
+	}
+}
+
+const (
+	defaultPingCount    = 3
+	defaultPingInterval = time.Second
+)
+
+// pingSettings resolves a server's packet count, packet interval and socket
+// mode, the server's own settings taking precedence over the global ones.
+func pingSettings(cfg *Config, server Server) (count int, interval time.Duration, privileged bool, err error) {
+	count = defaultPingCount
+	if cfg.PingCount > 0 {
+		count = cfg.PingCount
+	}
+	if server.PingCount > 0 {
+		count = server.PingCount
+	}
+
+	interval = defaultPingInterval
+	for _, value := range []string{cfg.PingInterval, server.PingInterval} {
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return 0, 0, false, fmt.Errorf("invalid ping_interval %q for %s", value, server.Name)
+		}
+		interval = d
+	}
+
+	privileged = cfg.PingPrivileged
+	if server.PingPrivileged != nil {
+		privileged = *server.PingPrivileged
+	}
+	return count, interval, privileged, nil
+}
+
+// errPingPermission is the result error of a ping check that could open
+// neither a raw nor an unprivileged ICMP socket.
+var errPingPermission = errors.New("not permitted to send pings: grant CAP_NET_RAW or allow unprivileged ICMP with the net.ipv4.ping_group_range sysctl")
+
+var pingPermissionWarning sync.Once
+
+// warnPingPermission logs how to fix ping permissions, once per run, since
+// every ping check fails the same way until it is fixed.
+func warnPingPermission(err error) {
+	pingPermissionWarning.Do(func() {
+		slog.Warn("Ping checks are skipped: the process may not open raw or unprivileged ICMP sockets. Run InfraPulse with CAP_NET_RAW (e.g. setcap cap_net_raw+ep on the binary) or allow unprivileged ICMP with sysctl net.ipv4.ping_group_range", "error", err)
+	})
+}
+
+// PingChecker pings a server that has no other checks.
+type PingChecker struct{ Service Service }
+
+func (c PingChecker) Check(ctx context.Context) CheckResult {
+	service := c.Service
+	stats, err := runPing(ctx, service, service.PingPrivileged)
+	if errors.Is(err, os.ErrPermission) {
+		// Not being allowed to open the socket says nothing about the
+		// host, so try the other socket mode before giving up.
+		stats, err = runPing(ctx, service, !service.PingPrivileged)
+		if errors.Is(err, os.ErrPermission) {
+			warnPingPermission(err)
+			return CheckResult{Service: service, Status: StatusUnknown, Error: errPingPermission}
+		}
+	}
+	if err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	// Judged on the packets actually sent: one not sent can't be answered.
+	if !service.PingPolicy.satisfied(stats.PacketsSent, stats.PacketsRecv) {
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("received %d of %d ping replies, policy %s not met", stats.PacketsRecv, stats.PacketsSent, service.PingPolicy)}
+	}
+	return CheckResult{Service: service, Status: StatusUp, Latency: stats.AvgRtt}
+}
+
+// pingTimeout is how long a ping check may take: long enough to send every
+// packet at the ping interval, plus the check's timeout for the last reply.
+func pingTimeout(service Service) time.Duration {
+	return time.Duration(max(service.PingCount-1, 0))*service.PingInterval + service.Timeout
+}
+
+// runPing pings the service's host with a raw ICMP socket when privileged is
+// set, or an unprivileged datagram socket otherwise.
+func runPing(ctx context.Context, service Service, privileged bool) (*probing.Statistics, error) {
+	pinger, err := probing.NewPinger(service.Host)
+	if err != nil {
+		return nil, err
+	}
+	pinger.Count = service.PingCount
+	pinger.Interval = service.PingInterval
+	pinger.Timeout = pingTimeout(service)
+	pinger.SetPrivileged(privileged)
+	pinger.Source = service.SourceAddress
+	if err := pinger.RunWithContext(ctx); err != nil {
+		return nil, err
+	}
+	return pinger.Statistics(), nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestPingPolicySatisfied(t *testing.T) {
+	tests := []struct {
+		policy     PingPolicy
+		sent, recv int
+		want       bool
+	}{
+		{"", 3, 1, true},
+		{"any", 3, 0, false},
+		{"any", 0, 0, false},
+		{"all", 3, 3, true},
+		{"all", 3, 2, false},
+		{"majority", 3, 2, true},
+		{"majority", 4, 2, false},
+		{"majority", 1, 1, true},
+		{"80%", 5, 4, true},
+		{"80%", 5, 3, false},
+		{"100%", 2, 2, true},
+		{"50%", 4, 2, true},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.satisfied(tt.sent, tt.recv); got != tt.want {
+			t.Errorf("PingPolicy(%q).satisfied(%d, %d) = %v, want %v", tt.policy, tt.sent, tt.recv, got, tt.want)
+		}
+	}
+}
+
+func TestPingPolicyPercent(t *testing.T) {
+	for _, policy := range []PingPolicy{"0%", "101%", "most", "80"} {
+		if _, err := policy.percent(); err == nil {
+			t.Errorf("PingPolicy(%q).percent() succeeded, want an error", policy)
+		}
+	}
+}
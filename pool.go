@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// defaultCheckWeights is the number of worker slots each check type occupies
+// while running. Heavier check types can be given a larger weight so that a
+// handful of them don't crowd out many cheap ones.
+var defaultCheckWeights = map[string]int64{
+	"ping":       1,
+	"tcp":        1,
+	"syn":        1,
+	"tls":        1,
+	"stun":       1,
+	"dns":        1,
+	"snmp":       1,
+	"mx":         1,
+	"exec":       1,
+	"grpc":       1,
+	"http":       1,
+	"tcp_script": 1,
+	"tcp_probe":  1,
+	"udp":        1,
+}
+
+// checkType names the kind of check a service performs.
+func (s Service) checkType() string {
+	switch {
+	case s.Exec != nil:
+		return "exec"
+	case s.Port == 0:
+		return "ping"
+	case s.UDP:
+		return "udp"
+	case s.HTTP != nil:
+		return "http"
+	case s.STUN != nil:
+		return "stun"
+	case s.DNS != nil:
+		return "dns"
+	case s.SNMP != nil:
+		return "snmp"
+	case s.MX != nil:
+		return "mx"
+	case s.TCPScript != nil:
+		return "tcp_script"
+	case s.TCPProbe != nil:
+		return "tcp_probe"
+	case s.GRPCHealth, s.GRPCReflection != nil:
+		return "grpc"
+	case s.TLS:
+		return "tls"
+	case s.SYN:
+		return "syn"
+	default:
+		return "tcp"
+	}
+}
+
+// checkPool fans checks out concurrently, bounded by a weighted semaphore.
+// Checks to the same host are also capped separately, so a host with many
+// monitored ports doesn't see a burst of simultaneous connections that a
+// firewall could take for a port scan.
+type checkPool struct {
+	sem      *semaphore.Weighted // nil when concurrency is unlimited
+	capacity int64
+	weights  map[string]int64
+
+	perHost  int64 // Concurrent checks per host, 0 for unlimited
+	hostMu   sync.Mutex
+	hostSems map[string]*semaphore.Weighted
+}
+
+// defaultMaxConcurrency bounds the worker slots when max_concurrency is not
+// set, keeping file descriptor use in check on large fleets.
+const defaultMaxConcurrency = 50
+
+// defaultMaxPerHost bounds the concurrent checks to one host when
+// max_per_host is not set.
+const defaultMaxPerHost = 4
+
+// newCheckPool builds a pool with maxConcurrency worker slots and at most
+// maxPerHost checks running against one host: 0 for the defaults, -1 for
+// unlimited.
+func newCheckPool(maxConcurrency, maxPerHost int, weights map[string]int) (*checkPool, error) {
+	switch {
+	case maxConcurrency == 0:
+		maxConcurrency = defaultMaxConcurrency
+	case maxConcurrency == -1:
+		maxConcurrency = 0
+	case maxConcurrency < 0:
+		return nil, fmt.Errorf("max_concurrency must be positive or -1 for unlimited, got %d", maxConcurrency)
+	}
+	switch {
+	case maxPerHost == 0:
+		maxPerHost = defaultMaxPerHost
+	case maxPerHost == -1:
+		maxPerHost = 0
+	case maxPerHost < 0:
+		return nil, fmt.Errorf("max_per_host must be positive or -1 for unlimited, got %d", maxPerHost)
+	}
+
+	p := &checkPool{capacity: int64(maxConcurrency), weights: make(map[string]int64), perHost: int64(maxPerHost), hostSems: make(map[string]*semaphore.Weighted)}
+	for checkType, weight := range defaultCheckWeights {
+		p.weights[checkType] = weight
+	}
+	for checkType, weight := range weights {
+		if _, ok := defaultCheckWeights[checkType]; !ok {
+			return nil, fmt.Errorf("check_weights: unknown check type %q", checkType)
+		}
+		if weight < 1 {
+			return nil, fmt.Errorf("check_weights: weight for %q must be at least 1, got %d", checkType, weight)
+		}
+		p.weights[checkType] = int64(weight)
+	}
+
+	if maxConcurrency > 0 {
+		p.sem = semaphore.NewWeighted(p.capacity)
+	}
+	return p, nil
+}
+
+// weight returns the slots a service's check occupies, clamped to the pool
+// capacity so an oversized weight can never block forever.
+func (p *checkPool) weight(service Service) int64 {
+	weight := p.weights[service.checkType()]
+	if p.capacity > 0 && weight > p.capacity {
+		weight = p.capacity
+	}
+	return weight
+}
+
+// hostSem returns the semaphore bounding checks to a host, or nil when the
+// per-host cap is off.
+func (p *checkPool) hostSem(host string) *semaphore.Weighted {
+	if p.perHost == 0 {
+		return nil
+	}
+	host = strings.ToLower(host)
+	p.hostMu.Lock()
+	defer p.hostMu.Unlock()
+	sem, ok := p.hostSems[host]
+	if !ok {
+		sem = semaphore.NewWeighted(p.perHost)
+		p.hostSems[host] = sem
+	}
+	return sem
+}
+
+// run starts a check for every service and returns a channel of results that
+// is closed once all checks have completed. If inFlight is non-nil, each check
+// is registered there while it is actually running. With jitter, each check
+// waits a random delay up to jitter before starting, so checks don't all open
+// their connections at the same instant. Once ctx is cancelled, checks that
+// have not started yet are skipped and no further results are delivered.
+func (p *checkPool) run(ctx context.Context, services []Service, inFlight *inFlightChecks, jitter time.Duration) <-chan CheckResult {
+	var wg sync.WaitGroup
+	results := make(chan CheckResult)
+
+	for _, service := range services {
+		wg.Add(1)
+		go func() {
+			if jitter > 0 {
+				select {
+				case <-time.After(rand.N(jitter)):
+				case <-ctx.Done():
+					wg.Done()
+					return
+				}
+			}
+			// The host slot is taken first, so a check waiting on its host
+			// doesn't hold worker slots other hosts could use.
+			if hostSem := p.hostSem(service.Host); hostSem != nil {
+				if err := hostSem.Acquire(ctx, 1); err != nil {
+					wg.Done()
+					return
+				}
+				defer hostSem.Release(1)
+			}
+			throttled := false
+			if p.sem != nil {
+				weight := p.weight(service)
+				if !p.sem.TryAcquire(weight) {
+					throttled = true
+					if err := p.sem.Acquire(ctx, weight); err != nil {
+						wg.Done()
+						return
+					}
+				}
+				defer p.sem.Release(weight)
+			}
+			if ctx.Err() != nil {
+				wg.Done()
+				return
+			}
+			if inFlight != nil {
+				serviceID := service.ID()
+				inFlight.start(serviceID)
+				defer inFlight.finish(serviceID)
+			}
+			checkService(ctx, service, throttled, &wg, results)
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reverseDNS enriches IP-specified hosts with their PTR name in output and
+// alerts. It is nil unless reverse_dns is enabled.
+var reverseDNS *reverseDNSCache
+
+const (
+	reverseDNSTTL     = time.Hour
+	reverseDNSTimeout = 2 * time.Second
+)
+
+// reverseDNSCache resolves PTR names in the background so a slow or broken
+// resolver never delays or fails a check; until a lookup completes the host
+// is shown as a bare IP.
+type reverseDNSCache struct {
+	mu      sync.Mutex
+	entries map[string]reverseDNSEntry
+}
+
+type reverseDNSEntry struct {
+	name     string // Empty when the IP has no PTR record
+	expires  time.Time
+	inFlight bool
+}
+
+func newReverseDNSCache() *reverseDNSCache {
+	return &reverseDNSCache{entries: make(map[string]reverseDNSEntry)}
+}
+
+// name returns the cached PTR name for ip, starting a lookup if there is no
+// fresh entry.
+func (c *reverseDNSCache) name(ip string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[ip]
+	if (!ok || time.Now().After(entry.expires)) && !entry.inFlight {
+		entry.inFlight = true
+		c.entries[ip] = entry
+		go c.lookup(ip)
+	}
+	return entry.name
+}
+
+func (c *reverseDNSCache) lookup(ip string) {
+	ctx, cancel := context.WithTimeout(context.Background(), reverseDNSTimeout)
+	defer cancel()
+
+	var name string
+	names, err := net.DefaultResolver.LookupAddr(ctx, ip)
+	if err != nil {
+		slog.Debug("Reverse DNS lookup failed", "ip", ip, "error", err)
+	} else if len(names) > 0 {
+		name = strings.TrimSuffix(names[0], ".")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[ip] = reverseDNSEntry{name: name, expires: time.Now().Add(reverseDNSTTL)}
+}
+
+// warm starts lookups for every IP host up front, so names are usually known
+// by the time the first results are printed.
+func (c *reverseDNSCache) warm(services []Service) {
+	for _, service := range services {
+		if net.ParseIP(service.Host) != nil {
+			c.name(service.Host)
+		}
+	}
+}
+
+// displayHost renders a host for output and alerts, e.g.
+// "10.0.0.5 (db01.internal)" when reverse DNS is enabled and has a name.
+func displayHost(host string) string {
+	if reverseDNS == nil || net.ParseIP(host) == nil {
+		return host
+	}
+	if name := reverseDNS.name(host); name != "" {
+		return host + " (" + name + ")"
+	}
+	return host
+}
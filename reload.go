@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// loopSettings are the monitoring loop's settings that are derived from the
+// configuration and take effect again when it is reloaded on SIGHUP.
+type loopSettings struct {
+	interval         time.Duration
+	failureThreshold int
+	massFailureDelay time.Duration
+	cycleTimeout     time.Duration
+	jitter           time.Duration
+	alertCooldown    time.Duration
+	sched            *schedule
+	maintenance      maintenanceSchedule
+}
+
+// maxJitter caps jitter, as a percentage of the loop tick, so a delayed check
+// still has most of the cycle to finish in.
+const maxJitter = 50
+
+func newLoopSettings(cfg *Config, services []Service, intervalFlag string) (loopSettings, error) {
+	var settings loopSettings
+
+	checkInterval := cfg.CheckInterval
+	if intervalFlag != "" {
+		checkInterval = intervalFlag
+	}
+	if checkInterval == "" {
+		checkInterval = "60s" // Default to 60 seconds if not specified
+	}
+	interval, err := parseCheckInterval(checkInterval)
+	if err != nil {
+		return settings, fmt.Errorf("invalid check interval: %w", err)
+	}
+	settings.interval = interval
+
+	settings.failureThreshold = cfg.FailureThreshold
+	if settings.failureThreshold == 0 {
+		settings.failureThreshold = 1
+	}
+	if settings.failureThreshold < 0 {
+		return settings, fmt.Errorf("invalid failure threshold %d, must be at least 1", cfg.FailureThreshold)
+	}
+
+	settings.massFailureDelay = 5 * time.Second
+	if cfg.MassFailureRecheckDelay != "" {
+		settings.massFailureDelay, err = time.ParseDuration(cfg.MassFailureRecheckDelay)
+		if err != nil {
+			return settings, fmt.Errorf("invalid mass failure recheck delay: %w", err)
+		}
+	}
+
+	settings.sched, err = newSchedule(services, interval)
+	if err != nil {
+		return settings, fmt.Errorf("invalid service interval: %w", err)
+	}
+	// The tick can be far shorter than any interval, 1s for intervals of
+	// 60s and 61s, so the default deadline is the shortest interval instead.
+	settings.cycleTimeout = settings.sched.shortest
+	if cfg.CycleTimeout != "" {
+		settings.cycleTimeout, err = time.ParseDuration(cfg.CycleTimeout)
+		if err != nil || settings.cycleTimeout <= 0 {
+			return settings, fmt.Errorf("invalid cycle timeout %q", cfg.CycleTimeout)
+		}
+	}
+	if cfg.Jitter < 0 || cfg.Jitter > maxJitter {
+		return settings, fmt.Errorf("invalid jitter %d, must be between 0 and %d percent", cfg.Jitter, maxJitter)
+	}
+	settings.jitter = settings.sched.tick * time.Duration(cfg.Jitter) / 100
+	if cfg.AlertCooldown != "" {
+		settings.alertCooldown, err = time.ParseDuration(cfg.AlertCooldown)
+		if err != nil || settings.alertCooldown < 0 {
+			return settings, fmt.Errorf("invalid alert cooldown %q", cfg.AlertCooldown)
+		}
+	}
+	settings.maintenance = newMaintenanceSchedule(cfg)
+	return settings, nil
+}
+
+// serviceIDs returns the set of IDs of services.
+func serviceIDs(services []Service) map[string]bool {
+	ids := make(map[string]bool, len(services))
+	for _, service := range services {
+		ids[service.ID()] = true
+	}
+	return ids
+}
+
+// diffServices counts the services in next that are not in prev, and the
+// ones in prev that are no longer in next.
+func diffServices(prev, next []Service) (added, removed int) {
+	prevIDs, nextIDs := serviceIDs(prev), serviceIDs(next)
+	for id := range nextIDs {
+		if !prevIDs[id] {
+			added++
+		}
+	}
+	for id := range prevIDs {
+		if !nextIDs[id] {
+			removed++
+		}
+	}
+	return added, removed
+}
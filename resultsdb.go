@@ -0,0 +1,148 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver, registered as "sqlite"
+)
+
+// resultsDBTimeFormat stores times as UTC text that sorts chronologically and
+// works with SQLite's date and time functions.
+const resultsDBTimeFormat = "2006-01-02T15:04:05.000Z"
+
+const resultsDBSchema = `
+CREATE TABLE IF NOT EXISTS results (
+	time        TEXT NOT NULL,
+	service_id  TEXT NOT NULL,
+	name        TEXT NOT NULL,
+	check_type  TEXT NOT NULL,
+	status      TEXT NOT NULL,
+	latency_ms  REAL NOT NULL,
+	duration_ms REAL NOT NULL,
+	error       TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS results_service_time ON results (service_id, time);
+CREATE INDEX IF NOT EXISTS results_time ON results (time);
+`
+
+// resultsDB records every check result in a SQLite file, set with -db, so
+// uptime and latency trends can be queried long after the in-memory history
+// has rolled over.
+type resultsDB struct {
+	db *sql.DB
+}
+
+// dbResult is a stored check result, as returned by GET /history.
+type dbResult struct {
+	Time      time.Time `json:"time"`
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Check     string    `json:"check"`
+	Status    Status    `json:"status"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+}
+
+func openResultsDB(path string) (*resultsDB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	// SQLite allows one writer at a time; a single connection keeps the
+	// loop's inserts and the API's reads from failing with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(resultsDBSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize %s: %w", path, err)
+	}
+	return &resultsDB{db: db}, nil
+}
+
+func (d *resultsDB) close() error {
+	return d.db.Close()
+}
+
+// record stores a cycle's results, logging rather than failing on error so a
+// full disk or locked file never stops monitoring. A nil resultsDB records
+// nothing.
+func (d *resultsDB) record(results []CheckResult) {
+	if d == nil {
+		return
+	}
+	if err := d.insert(results); err != nil {
+		slog.Warn("Failed to record results in the database", "error", err)
+	}
+}
+
+// insert stores a cycle's results in one transaction. Skipped checks did not
+// run and are not stored.
+func (d *resultsDB) insert(results []CheckResult) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO results (time, service_id, name, check_type, status, latency_ms, duration_ms, error) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, result := range results {
+		if result.Status == StatusSkipped {
+			continue
+		}
+		errorMsg := ""
+		if result.Error != nil {
+			errorMsg = result.Error.Error()
+		}
+		if _, err := stmt.Exec(result.Time.UTC().Format(resultsDBTimeFormat), result.Service.ID(), result.Service.Name, result.Service.checkType(), string(result.Status), milliseconds(result.Latency), milliseconds(result.Duration), errorMsg); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// recent returns up to limit results recorded since the given time, newest
+// first, for the service with the given ID or name, or for every service if
+// service is empty.
+func (d *resultsDB) recent(service string, since time.Time, limit int) ([]dbResult, error) {
+	rows, err := d.db.Query(`SELECT time, service_id, name, check_type, status, latency_ms, error FROM results
+		WHERE time >= ? AND (? = '' OR service_id = ? OR name = ?)
+		ORDER BY time DESC LIMIT ?`,
+		since.UTC().Format(resultsDBTimeFormat), service, service, service, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []dbResult{}
+	for rows.Next() {
+		var r dbResult
+		var at string
+		if err := rows.Scan(&at, &r.ID, &r.Name, &r.Check, &r.Status, &r.LatencyMs, &r.Error); err != nil {
+			return nil, err
+		}
+		r.Time, _ = time.Parse(resultsDBTimeFormat, at)
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// uptime returns the fraction of a service's results since the given time
+// that were UP or WARN, and how many results that covers. INACTIVE and
+// UNKNOWN results say nothing about the service and are left out.
+func (d *resultsDB) uptime(service string, since time.Time) (float64, int, error) {
+	var total, up int
+	err := d.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(status IN ('UP', 'WARN')), 0) FROM results
+		WHERE time >= ? AND status NOT IN ('INACTIVE', 'UNKNOWN') AND (service_id = ? OR name = ?)`,
+		since.UTC().Format(resultsDBTimeFormat), service, service).Scan(&total, &up)
+	if err != nil || total == 0 {
+		return 0, total, err
+	}
+	return float64(up) / float64(total), total, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// minCheckInterval is the shortest interval a service can be checked at. A
+// shorter one, usually a typo such as "1ms", would hammer every target and
+// never let a cycle finish.
+const minCheckInterval = time.Second
+
+// parseCheckInterval parses a check interval and enforces minCheckInterval.
+func parseCheckInterval(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		if _, numErr := strconv.Atoi(s); numErr == nil {
+			return 0, fmt.Errorf("%q has no unit, did you mean %ss?", s, s)
+		}
+		return 0, err
+	}
+	if d < minCheckInterval {
+		return 0, fmt.Errorf("%s is shorter than the minimum of %s", d, minCheckInterval)
+	}
+	return d, nil
+}
+
+// schedule decides which services are due on each tick of the monitoring
+// loop. The loop ticks at the greatest common divisor of all intervals and a
+// service runs on every tick that is a multiple of its own interval, so with
+// a single global interval every service runs on every tick.
+type schedule struct {
+	tick     time.Duration
+	shortest time.Duration // Shortest interval any service runs at
+	every    []int64       // Ticks between runs, per service index
+}
+
+func newSchedule(services []Service, global time.Duration) (*schedule, error) {
+	intervals := make([]time.Duration, len(services))
+	tick, shortest := global, time.Duration(0)
+	for i, service := range services {
+		intervals[i] = global
+		if service.Interval != "" {
+			d, err := parseCheckInterval(service.Interval)
+			if err != nil {
+				return nil, fmt.Errorf("invalid interval for %s: %w", service.Name, err)
+			}
+			intervals[i] = d
+		}
+		tick = gcd(tick, intervals[i])
+		if shortest == 0 || intervals[i] < shortest {
+			shortest = intervals[i]
+		}
+	}
+	if shortest == 0 {
+		shortest = global
+	}
+
+	s := &schedule{tick: tick, shortest: shortest, every: make([]int64, len(services))}
+	for i, interval := range intervals {
+		s.every[i] = int64(interval / tick)
+	}
+	return s, nil
+}
+
+// due returns the services to check on tick n, counting from 1.
+func (s *schedule) due(services []Service, n int64) []Service {
+	var due []Service
+	for i, service := range services {
+		if n%s.every[i] == 0 {
+			due = append(due, service)
+		}
+	}
+	return due
+}
+
+func gcd(a, b time.Duration) time.Duration {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCheckInterval(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr string
+	}{
+		{"60s", time.Minute, ""},
+		{"1s", time.Second, ""},
+		{"1h30m", 90 * time.Minute, ""},
+		{"60", 0, "did you mean 60s?"},
+		{"999ms", 0, "shorter than the minimum of 1s"},
+		{"1ms", 0, "shorter than the minimum"},
+		{"-5s", 0, "shorter than the minimum"},
+		{"soon", 0, "invalid duration"},
+	}
+	for _, tt := range tests {
+		got, err := parseCheckInterval(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseCheckInterval(%q) = %v, %v, want an error containing %q", tt.in, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseCheckInterval(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestGCD(t *testing.T) {
+	tests := []struct{ a, b, want time.Duration }{
+		{60 * time.Second, 90 * time.Second, 30 * time.Second},
+		{time.Minute, time.Minute, time.Minute},
+		{45 * time.Second, 10 * time.Second, 5 * time.Second},
+		{7 * time.Second, 3 * time.Second, time.Second},
+		{1500 * time.Millisecond, time.Second, 500 * time.Millisecond},
+		{time.Minute, 0, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := gcd(tt.a, tt.b); got != tt.want {
+			t.Errorf("gcd(%s, %s) = %s, want %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	tests := []struct {
+		name         string
+		global       time.Duration
+		intervals    []string
+		wantTick     time.Duration
+		wantShortest time.Duration
+		wantDue      map[int64][]string // Services due on each tick
+	}{
+		{
+			name:         "global interval only",
+			global:       time.Minute,
+			intervals:    []string{"", ""},
+			wantTick:     time.Minute,
+			wantShortest: time.Minute,
+			wantDue:      map[int64][]string{1: {"s0", "s1"}, 2: {"s0", "s1"}},
+		},
+		{
+			name:         "per-service intervals",
+			global:       time.Minute,
+			intervals:    []string{"", "30s", "90s"},
+			wantTick:     30 * time.Second,
+			wantShortest: 30 * time.Second,
+			wantDue:      map[int64][]string{1: {"s1"}, 2: {"s0", "s1"}, 3: {"s1", "s2"}, 6: {"s0", "s1", "s2"}},
+		},
+		{
+			name:         "intervals without a common multiple of the global",
+			global:       time.Minute,
+			intervals:    []string{"", "45s"},
+			wantTick:     15 * time.Second,
+			wantShortest: 45 * time.Second,
+			wantDue:      map[int64][]string{1: nil, 3: {"s1"}, 4: {"s0"}, 12: {"s0", "s1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			services := make([]Service, len(tt.intervals))
+			for i, interval := range tt.intervals {
+				services[i] = Service{Name: "s" + string(rune('0'+i)), Interval: interval}
+			}
+			s, err := newSchedule(services, tt.global)
+			if err != nil {
+				t.Fatalf("newSchedule: %v", err)
+			}
+			if s.tick != tt.wantTick || s.shortest != tt.wantShortest {
+				t.Errorf("tick, shortest = %s, %s, want %s, %s", s.tick, s.shortest, tt.wantTick, tt.wantShortest)
+			}
+			for n, want := range tt.wantDue {
+				var got []string
+				for _, service := range s.due(services, n) {
+					got = append(got, service.Name)
+				}
+				if !slices.Equal(got, want) {
+					t.Errorf("due(%d) = %q, want %q", n, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewScheduleRejectsShortInterval(t *testing.T) {
+	if _, err := newSchedule([]Service{{Name: "web", Interval: "100ms"}}, time.Minute); err == nil {
+		t.Error("newSchedule accepted a 100ms interval")
+	}
+}
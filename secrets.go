@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resolveSecretFiles fills credential fields from their `*_file` and `*_env`
+// counterparts, so secrets can be mounted as individual files (Kubernetes or
+// Docker secrets) or passed in the environment instead of being written into
+// the YAML.
+func resolveSecretFiles(cfg *Config) error {
+	if err := readSecretEnv(&cfg.SMTP.Password, cfg.SMTP.PasswordEnv, "smtp.password"); err != nil {
+		return err
+	}
+	if err := readSecretFile(&cfg.SMTP.Username, cfg.SMTP.UsernameFile, "smtp.username"); err != nil {
+		return err
+	}
+	if err := readSecretFile(&cfg.SMTP.Password, cfg.SMTP.PasswordFile, "smtp.password"); err != nil {
+		return err
+	}
+	if err := readSecretFile(&cfg.Kafka.SASL.Password, cfg.Kafka.SASL.PasswordFile, "kafka.sasl.password"); err != nil {
+		return err
+	}
+	if err := readSecretEnv(&cfg.APIToken, cfg.APITokenEnv, "api_token"); err != nil {
+		return err
+	}
+	if err := readSecretFile(&cfg.APIToken, cfg.APITokenFile, "api_token"); err != nil {
+		return err
+	}
+	if err := resolveChannelSecrets("", &cfg.Slack, &cfg.Discord, &cfg.Telegram, &cfg.PagerDuty, &cfg.Webhook); err != nil {
+		return err
+	}
+	for i := range cfg.Servers {
+		for j := range cfg.Servers[i].HTTPChecks {
+			check := &cfg.Servers[i].HTTPChecks[j]
+			if err := readSecretEnv(&check.BearerToken, check.BearerTokenEnv, check.URL+": bearer_token"); err != nil {
+				return err
+			}
+			if err := readSecretFile(&check.BearerToken, check.BearerTokenFile, check.URL+": bearer_token"); err != nil {
+				return err
+			}
+			if check.BasicAuth != nil {
+				if err := readSecretEnv(&check.BasicAuth.Password, check.BasicAuth.PasswordEnv, check.URL+": basic_auth.password"); err != nil {
+					return err
+				}
+				if err := readSecretFile(&check.BasicAuth.Password, check.BasicAuth.PasswordFile, check.URL+": basic_auth.password"); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for name, group := range cfg.Groups {
+		if err := resolveChannelSecrets("groups."+name+".", group.Slack, group.Discord, group.Telegram, group.PagerDuty, group.Webhook); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveChannelSecrets resolves the secrets of the channels a group can
+// override, skipping the ones that are nil.
+func resolveChannelSecrets(prefix string, slack *SlackConfig, discord *DiscordConfig, telegram *TelegramConfig, pagerDuty *PagerDutyConfig, webhook *WebhookConfig) error {
+	if slack != nil {
+		if err := readSecretFile(&slack.WebhookURL, slack.WebhookURLFile, prefix+"slack.webhook_url"); err != nil {
+			return err
+		}
+	}
+	if discord != nil {
+		if err := readSecretFile(&discord.WebhookURL, discord.WebhookURLFile, prefix+"discord.webhook_url"); err != nil {
+			return err
+		}
+	}
+	if telegram != nil {
+		if err := readSecretFile(&telegram.BotToken, telegram.BotTokenFile, prefix+"telegram.bot_token"); err != nil {
+			return err
+		}
+	}
+	if pagerDuty != nil {
+		if err := readSecretFile(&pagerDuty.RoutingKey, pagerDuty.RoutingKeyFile, prefix+"pagerduty.routing_key"); err != nil {
+			return err
+		}
+	}
+	if webhook != nil {
+		return readSecretFile(&webhook.URL, webhook.URLFile, prefix+"webhook.url")
+	}
+	return nil
+}
+
+// secretPathsRelativeTo makes the relative `*_file` paths of cfg absolute
+// against dir, the directory of the file they were read from, so a secret
+// next to the config is found whatever directory InfraPulse is started in.
+// Paths that are already absolute are left alone, so it is safe to call again
+// once more files have been merged into cfg.
+func secretPathsRelativeTo(cfg *Config, dir string) {
+	paths := []*string{
+		&cfg.SMTP.UsernameFile, &cfg.SMTP.PasswordFile, &cfg.Kafka.SASL.PasswordFile, &cfg.APITokenFile,
+		&cfg.Slack.WebhookURLFile, &cfg.Discord.WebhookURLFile, &cfg.Telegram.BotTokenFile, &cfg.PagerDuty.RoutingKeyFile, &cfg.Webhook.URLFile,
+	}
+	for _, group := range cfg.Groups {
+		if group.Slack != nil {
+			paths = append(paths, &group.Slack.WebhookURLFile)
+		}
+		if group.Discord != nil {
+			paths = append(paths, &group.Discord.WebhookURLFile)
+		}
+		if group.Telegram != nil {
+			paths = append(paths, &group.Telegram.BotTokenFile)
+		}
+		if group.PagerDuty != nil {
+			paths = append(paths, &group.PagerDuty.RoutingKeyFile)
+		}
+		if group.Webhook != nil {
+			paths = append(paths, &group.Webhook.URLFile)
+		}
+	}
+	for i := range cfg.Servers {
+		for j := range cfg.Servers[i].HTTPChecks {
+			check := &cfg.Servers[i].HTTPChecks[j]
+			paths = append(paths, &check.BearerTokenFile)
+			if check.BasicAuth != nil {
+				paths = append(paths, &check.BasicAuth.PasswordFile)
+			}
+		}
+	}
+	for _, path := range paths {
+		if *path == "" || filepath.IsAbs(*path) {
+			continue
+		}
+		*path = filepath.Join(dir, *path)
+		if abs, err := filepath.Abs(*path); err == nil {
+			*path = abs
+		}
+	}
+}
+
+// readSecretFile sets *value to the whitespace-trimmed contents of path. It
+// is a no-op when path is empty and an error when the inline value is also
+// set, since silently preferring one of the two hides configuration mistakes.
+func readSecretFile(value *string, path, field string) error {
+	if path == "" {
+		return nil
+	}
+	if *value != "" {
+		return fmt.Errorf("%s and %s_file are both set; use only one", field, field)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s_file: %w", field, err)
+	}
+	*value = strings.TrimSpace(string(data))
+	return nil
+}
+
+// readSecretEnv sets *value from the environment variable name. An unset
+// variable is an error, so a typo never means authenticating with an empty
+// secret.
+func readSecretEnv(value *string, name, field string) error {
+	if name == "" {
+		return nil
+	}
+	if *value != "" {
+		return fmt.Errorf("%s and %s_env are both set; use only one", field, field)
+	}
+	env, ok := os.LookupEnv(name)
+	if !ok || env == "" {
+		return fmt.Errorf("%s_env: environment variable %s is not set", field, name)
+	}
+	*value = env
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigSecretFilesRelativeToConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"servers.yaml": `include: ["conf.d/*.yaml"]
+servers:
+  - name: api
+    host: api.example.com
+    http_checks:
+      - url: https://api.example.com/health
+        bearer_token_file: token
+`,
+		"conf.d/more.yaml": `servers:
+  - name: admin
+    host: admin.example.com
+    http_checks:
+      - url: https://admin.example.com/health
+        basic_auth: {username: admin, password_file: admin_password}
+`,
+		"config.yaml":           "api_token_file: secrets/api_token\n",
+		"token":                 "bearer-secret\n",
+		"conf.d/admin_password": "admin-secret\n",
+		"secrets/api_token":     "api-secret\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	cfg, err := loadConfig(filepath.Join(dir, "servers.yaml"), filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if got := cfg.Servers[0].HTTPChecks[0].BearerToken; got != "bearer-secret" {
+		t.Errorf("bearer_token = %q, want bearer-secret", got)
+	}
+	if got := cfg.Servers[1].HTTPChecks[0].BasicAuth.Password; got != "admin-secret" {
+		t.Errorf("basic_auth.password = %q, want admin-secret", got)
+	}
+	if cfg.APIToken != "api-secret" {
+		t.Errorf("api_token = %q, want api-secret", cfg.APIToken)
+	}
+}
+
+func TestReadSecretFileConflict(t *testing.T) {
+	value := "inline"
+	if err := readSecretFile(&value, "/nonexistent", "bearer_token"); err == nil {
+		t.Fatal("readSecretFile accepted both an inline value and a file")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// SelfMonitorConfig enables resource checks on the host InfraPulse itself
+// runs on. A monitor that is starved of CPU, memory, disk or file descriptors
+// produces false results, so pressure is surfaced before it corrupts them.
+type SelfMonitorConfig struct {
+	Enabled       bool    `yaml:"enabled"`
+	CPUPercent    float64 `yaml:"cpu_percent"`    // Default 90
+	MemoryPercent float64 `yaml:"memory_percent"` // Default 90
+	DiskPercent   float64 `yaml:"disk_percent"`   // Default 90
+	DiskPath      string  `yaml:"disk_path"`      // Default "/"
+	FDPercent     float64 `yaml:"fd_percent"`     // Default 80
+}
+
+// hostUsage is a snapshot of resource usage, each as a percentage.
+type hostUsage struct {
+	CPU    float64
+	Memory float64
+	Disk   float64
+	FD     float64
+}
+
+type selfMonitor struct {
+	cfg       SelfMonitorConfig
+	hostname  string
+	cpu       cpuSample
+	pressured map[string]bool
+}
+
+func newSelfMonitor(cfg SelfMonitorConfig) *selfMonitor {
+	if cfg.CPUPercent == 0 {
+		cfg.CPUPercent = 90
+	}
+	if cfg.MemoryPercent == 0 {
+		cfg.MemoryPercent = 90
+	}
+	if cfg.DiskPercent == 0 {
+		cfg.DiskPercent = 90
+	}
+	if cfg.DiskPath == "" {
+		cfg.DiskPath = "/"
+	}
+	if cfg.FDPercent == 0 {
+		cfg.FDPercent = 80
+	}
+	hostname, _ := os.Hostname()
+	return &selfMonitor{cfg: cfg, hostname: hostname, pressured: make(map[string]bool)}
+}
+
+// check samples host resources, logs any that exceed their threshold, and
+// returns alerts for resources that have just come under pressure.
+func (m *selfMonitor) check() []Alert {
+	usage, cpu, err := readHostUsage(m.cfg.DiskPath, m.cpu)
+	if err != nil {
+		slog.Warn("Self-monitoring failed", "error", err)
+		return nil
+	}
+	m.cpu = cpu
+
+	thresholds := []struct {
+		resource string
+		value    float64
+		limit    float64
+	}{
+		{"cpu", usage.CPU, m.cfg.CPUPercent},
+		{"memory", usage.Memory, m.cfg.MemoryPercent},
+		{"disk", usage.Disk, m.cfg.DiskPercent},
+		{"file descriptors", usage.FD, m.cfg.FDPercent},
+	}
+
+	var alerts []Alert
+	for _, t := range thresholds {
+		over := t.value > t.limit
+		if over {
+			slog.Warn("Monitoring host under resource pressure", "resource", t.resource, "percent", fmt.Sprintf("%.1f", t.value), "threshold", t.limit)
+			if !m.pressured[t.resource] {
+				alerts = append(alerts, m.alert(t.resource, t.value, t.limit))
+			}
+		}
+		m.pressured[t.resource] = over
+	}
+	return alerts
+}
+
+func (m *selfMonitor) alert(resource string, value, limit float64) Alert {
+	result := CheckResult{
+		Service: Service{Name: "InfraPulse monitoring host", Host: m.hostname},
+		Status:  StatusWarn,
+		Error:   fmt.Errorf("%s usage %.1f%% exceeds %.0f%%", resource, value, limit),
+	}
+	message := fmt.Sprintf("Monitoring Host Resource Alert\n\nHost: %s\nTime: %s\nResource: %s\nUsage: %.1f%%\nThreshold: %.0f%%\nDetails: InfraPulse itself is under resource pressure. Check results may be unreliable until this is resolved.\n", m.hostname, time.Now().Format(time.RFC1123), resource, value, limit)
+	return newAlert("self", result, message)
+}
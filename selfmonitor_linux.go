@@ -0,0 +1,112 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// cpuSample holds cumulative CPU jiffies from /proc/stat.
+type cpuSample struct {
+	idle, total uint64
+}
+
+// readHostUsage reads resource usage from /proc and statfs. CPU usage is the
+// busy share since the previous sample, so it reads 0 on the first call.
+func readHostUsage(diskPath string, prev cpuSample) (hostUsage, cpuSample, error) {
+	var usage hostUsage
+
+	cpu, err := readCPUSample()
+	if err != nil {
+		return usage, prev, err
+	}
+	if prev.total > 0 && cpu.total > prev.total {
+		idle := float64(cpu.idle - prev.idle)
+		total := float64(cpu.total - prev.total)
+		usage.CPU = (1 - idle/total) * 100
+	}
+
+	if usage.Memory, err = memoryPercent(); err != nil {
+		return usage, cpu, err
+	}
+
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(diskPath, &fs); err != nil {
+		return usage, cpu, fmt.Errorf("statfs %s: %w", diskPath, err)
+	}
+	if fs.Blocks > 0 {
+		usage.Disk = (1 - float64(fs.Bavail)/float64(fs.Blocks)) * 100
+	}
+
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return usage, cpu, err
+	}
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return usage, cpu, err
+	}
+	if limit.Cur > 0 {
+		usage.FD = float64(len(fds)) / float64(limit.Cur) * 100
+	}
+
+	return usage, cpu, nil
+}
+
+func readCPUSample() (cpuSample, error) {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return cpuSample{}, err
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return cpuSample{}, fmt.Errorf("unexpected /proc/stat format")
+	}
+
+	var sample cpuSample
+	for i, field := range fields[1:] {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return cpuSample{}, fmt.Errorf("unexpected /proc/stat value %q", field)
+		}
+		sample.total += v
+		if i == 3 || i == 4 { // idle, iowait
+			sample.idle += v
+		}
+	}
+	return sample, nil
+}
+
+func memoryPercent() (float64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var total, available float64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		v, _ := strconv.ParseFloat(fields[1], 64)
+		switch fields[0] {
+		case "MemTotal:":
+			total = v
+		case "MemAvailable:":
+			available = v
+		}
+	}
+	if total == 0 {
+		return 0, fmt.Errorf("MemTotal not found in /proc/meminfo")
+	}
+	return (1 - available/total) * 100, nil
+}
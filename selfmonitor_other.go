@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+type cpuSample struct{}
+
+// readHostUsage relies on /proc and is only implemented on Linux.
+func readHostUsage(diskPath string, prev cpuSample) (hostUsage, cpuSample, error) {
+	return hostUsage{}, prev, errors.New("self-monitoring is only supported on Linux")
+}
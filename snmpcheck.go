@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// SNMPCheck polls a network device over SNMP and compares the values it
+// reports, such as CPU load, interface status or uptime, against thresholds.
+// Reachability alone says little about a switch or router that is up but
+// overloaded or has lost a link.
+type SNMPCheck struct {
+	Port      int    `yaml:"port"`      // Defaults to 161
+	Version   string `yaml:"version"`   // "1", "2c" (default) or "3"
+	Community string `yaml:"community"` // v1 and v2c, defaults to "public"
+
+	// SNMPv3 user-based security. Authentication and privacy are used when
+	// their passphrase is set.
+	Username       string `yaml:"username"`
+	AuthProtocol   string `yaml:"auth_protocol"` // MD5, SHA (default), SHA224, SHA256, SHA384 or SHA512
+	AuthPassphrase string `yaml:"auth_passphrase"`
+	PrivProtocol   string `yaml:"priv_protocol"` // DES, AES (default), AES192, AES256, AES192C or AES256C
+	PrivPassphrase string `yaml:"priv_passphrase"`
+
+	OIDs []SNMPOID `yaml:"oids"`
+}
+
+// SNMPOID is one value to poll and the conditions that mark the check DOWN
+// or WARN. A condition is a comparison with the polled value, such as "> 90"
+// or "!= 1"; it compares numerically when both sides are numbers.
+type SNMPOID struct {
+	OID  string `yaml:"oid"`
+	Name string `yaml:"name"` // Label in results and alerts, the OID if empty
+	Down string `yaml:"down"` // Condition that marks the check DOWN
+	Warn string `yaml:"warn"` // Condition that marks the check WARN
+}
+
+const snmpDefaultPort = 161
+
+var snmpAuthProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+	"MD5": gosnmp.MD5, "SHA": gosnmp.SHA, "SHA224": gosnmp.SHA224,
+	"SHA256": gosnmp.SHA256, "SHA384": gosnmp.SHA384, "SHA512": gosnmp.SHA512,
+}
+
+var snmpPrivProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+	"DES": gosnmp.DES, "AES": gosnmp.AES, "AES192": gosnmp.AES192,
+	"AES256": gosnmp.AES256, "AES192C": gosnmp.AES192C, "AES256C": gosnmp.AES256C,
+}
+
+func (c *SNMPCheck) port() int {
+	if c.Port == 0 {
+		return snmpDefaultPort
+	}
+	return c.Port
+}
+
+func (c *SNMPCheck) version() string {
+	if c.Version == "" {
+		return "2c"
+	}
+	return c.Version
+}
+
+func (o SNMPOID) label() string {
+	if o.Name != "" {
+		return o.Name
+	}
+	return o.OID
+}
+
+// validate reports what is wrong with the check's definition, if anything.
+func (c *SNMPCheck) validate() []string {
+	var problems []string
+	switch c.version() {
+	case "1", "2c":
+	case "3":
+		if c.Username == "" {
+			problems = append(problems, "username is required for version 3")
+		}
+		if _, ok := snmpAuthProtocols[strings.ToUpper(c.AuthProtocol)]; c.AuthProtocol != "" && !ok {
+			problems = append(problems, fmt.Sprintf("unknown auth_protocol %q", c.AuthProtocol))
+		}
+		if _, ok := snmpPrivProtocols[strings.ToUpper(c.PrivProtocol)]; c.PrivProtocol != "" && !ok {
+			problems = append(problems, fmt.Sprintf("unknown priv_protocol %q", c.PrivProtocol))
+		}
+		if c.PrivPassphrase != "" && c.AuthPassphrase == "" {
+			problems = append(problems, "priv_passphrase requires auth_passphrase")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("version %q must be 1, 2c or 3", c.Version))
+	}
+	if len(c.OIDs) == 0 {
+		problems = append(problems, "at least one oid is required")
+	}
+	if len(c.OIDs) > gosnmp.MaxOids {
+		problems = append(problems, fmt.Sprintf("at most %d oids can be polled, got %d", gosnmp.MaxOids, len(c.OIDs)))
+	}
+	for i, oid := range c.OIDs {
+		if oid.OID == "" {
+			problems = append(problems, fmt.Sprintf("oids[%d]: oid is required", i))
+		}
+		if _, err := parseSNMPCondition(oid.Down); oid.Down != "" && err != nil {
+			problems = append(problems, fmt.Sprintf("oids[%d].down: %v", i, err))
+		}
+		if _, err := parseSNMPCondition(oid.Warn); oid.Warn != "" && err != nil {
+			problems = append(problems, fmt.Sprintf("oids[%d].warn: %v", i, err))
+		}
+	}
+	return problems
+}
+
+// client returns an SNMP client for the check, not yet connected, sending
+// from source if it is set.
+func (c *SNMPCheck) client(ctx context.Context, host, source string, timeout time.Duration) *gosnmp.GoSNMP {
+	client := &gosnmp.GoSNMP{
+		Context:   ctx,
+		Target:    host,
+		Port:      uint16(c.port()),
+		Community: c.Community,
+		Timeout:   timeout,
+		Retries:   0, // Failed checks are retried by the retries setting
+		MaxOids:   gosnmp.MaxOids,
+	}
+	if source != "" {
+		client.LocalAddr = net.JoinHostPort(source, "0")
+	}
+	if client.Community == "" {
+		client.Community = "public"
+	}
+	switch c.version() {
+	case "1":
+		client.Version = gosnmp.Version1
+	case "2c":
+		client.Version = gosnmp.Version2c
+	case "3":
+		client.Version = gosnmp.Version3
+		client.SecurityModel = gosnmp.UserSecurityModel
+		params := &gosnmp.UsmSecurityParameters{UserName: c.Username, AuthenticationProtocol: gosnmp.NoAuth, PrivacyProtocol: gosnmp.NoPriv}
+		client.MsgFlags = gosnmp.NoAuthNoPriv
+		if c.AuthPassphrase != "" {
+			params.AuthenticationProtocol = gosnmp.SHA
+			if c.AuthProtocol != "" {
+				params.AuthenticationProtocol = snmpAuthProtocols[strings.ToUpper(c.AuthProtocol)]
+			}
+			params.AuthenticationPassphrase = c.AuthPassphrase
+			client.MsgFlags = gosnmp.AuthNoPriv
+		}
+		if c.PrivPassphrase != "" {
+			params.PrivacyProtocol = gosnmp.AES
+			if c.PrivProtocol != "" {
+				params.PrivacyProtocol = snmpPrivProtocols[strings.ToUpper(c.PrivProtocol)]
+			}
+			params.PrivacyPassphrase = c.PrivPassphrase
+			client.MsgFlags = gosnmp.AuthPriv
+		}
+		client.SecurityParameters = params
+	}
+	return client
+}
+
+// SNMPChecker runs a server's snmp check.
+type SNMPChecker struct{ Service Service }
+
+// Check GETs the configured OIDs and reports the service DOWN if the device
+// doesn't answer, lacks an OID or a value meets its down condition, and WARN
+// if a value meets its warn condition.
+func (c SNMPChecker) Check(ctx context.Context) CheckResult {
+	service, timeout := c.Service, c.Service.Timeout
+	check := service.SNMP
+	client := check.client(ctx, service.Host, service.SourceAddress, timeout)
+
+	start := time.Now()
+	if err := client.Connect(); err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("failed to connect: %w", err)}
+	}
+	defer client.Conn.Close()
+
+	oids := make([]string, len(check.OIDs))
+	for i, oid := range check.OIDs {
+		oids[i] = oid.OID
+	}
+	packet, err := client.Get(oids)
+	latency := time.Since(start)
+	if err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	if packet.Error != gosnmp.NoError {
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("device returned error %s", packet.Error)}
+	}
+
+	values := make(map[string]gosnmp.SnmpPDU, len(packet.Variables))
+	for _, pdu := range packet.Variables {
+		values[strings.TrimPrefix(pdu.Name, ".")] = pdu
+	}
+
+	var down, warn []string
+	for _, oid := range check.OIDs {
+		pdu, ok := values[strings.TrimPrefix(oid.OID, ".")]
+		if !ok || pdu.Type == gosnmp.NoSuchObject || pdu.Type == gosnmp.NoSuchInstance || pdu.Type == gosnmp.EndOfMibView {
+			down = append(down, fmt.Sprintf("%s: no such object", oid.label()))
+			continue
+		}
+		value := snmpValue(pdu)
+		if condition, _ := parseSNMPCondition(oid.Down); oid.Down != "" && condition.matches(value) {
+			down = append(down, fmt.Sprintf("%s is %s (down %s)", oid.label(), value, oid.Down))
+		} else if condition, _ := parseSNMPCondition(oid.Warn); oid.Warn != "" && condition.matches(value) {
+			warn = append(warn, fmt.Sprintf("%s is %s (warn %s)", oid.label(), value, oid.Warn))
+		}
+	}
+	if len(down) > 0 {
+		return CheckResult{Service: service, Status: StatusDown, Error: errors.New(strings.Join(append(down, warn...), "; "))}
+	}
+	if len(warn) > 0 {
+		return CheckResult{Service: service, Status: StatusWarn, Latency: latency, Error: errors.New(strings.Join(warn, "; "))}
+	}
+	return CheckResult{Service: service, Status: StatusUp, Latency: latency}
+}
+
+// snmpValue renders a polled value for comparison and display: strings as
+// text, numbers, counters and time ticks in decimal.
+func snmpValue(pdu gosnmp.SnmpPDU) string {
+	switch pdu.Type {
+	case gosnmp.OctetString:
+		if b, ok := pdu.Value.([]byte); ok {
+			return string(b)
+		}
+	case gosnmp.ObjectIdentifier, gosnmp.IPAddress:
+		return fmt.Sprint(pdu.Value)
+	}
+	return gosnmp.ToBigInt(pdu.Value).String()
+}
+
+// snmpCondition is a parsed threshold such as "> 90".
+type snmpCondition struct {
+	op    string
+	value string
+}
+
+var snmpOperators = []string{">=", "<=", "!=", "==", ">", "<"}
+
+func parseSNMPCondition(s string) (snmpCondition, error) {
+	s = strings.TrimSpace(s)
+	for _, op := range snmpOperators {
+		if rest, ok := strings.CutPrefix(s, op); ok {
+			value := strings.TrimSpace(rest)
+			if value == "" {
+				return snmpCondition{}, fmt.Errorf("condition %q has no value", s)
+			}
+			if op != "==" && op != "!=" {
+				if _, err := strconv.ParseFloat(value, 64); err != nil {
+					return snmpCondition{}, fmt.Errorf("condition %q compares with %s, which needs a number", s, op)
+				}
+			}
+			return snmpCondition{op: op, value: value}, nil
+		}
+	}
+	return snmpCondition{}, fmt.Errorf("condition %q must start with >, >=, <, <=, == or !=", s)
+}
+
+// matches reports whether value meets the condition. Values compare as
+// numbers when both are numeric and as strings otherwise, where only == and
+// != can match.
+func (c snmpCondition) matches(value string) bool {
+	got, errGot := strconv.ParseFloat(value, 64)
+	want, errWant := strconv.ParseFloat(c.value, 64)
+	if errGot != nil || errWant != nil {
+		switch c.op {
+		case "==":
+			return value == c.value
+		case "!=":
+			return value != c.value
+		}
+		return false
+	}
+	switch c.op {
+	case ">":
+		return got > want
+	case ">=":
+		return got >= want
+	case "<":
+		return got < want
+	case "<=":
+		return got <= want
+	case "==":
+		return got == want
+	default:
+		return got != want
+	}
+}
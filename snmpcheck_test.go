@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+func TestParseSNMPCondition(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    snmpCondition
+		wantErr bool
+	}{
+		{"> 90", snmpCondition{">", "90"}, false},
+		{">=90", snmpCondition{">=", "90"}, false},
+		{"  <= 0.5 ", snmpCondition{"<=", "0.5"}, false},
+		{"< -10", snmpCondition{"<", "-10"}, false},
+		{"== up", snmpCondition{"==", "up"}, false},
+		{"!= 1", snmpCondition{"!=", "1"}, false},
+		{"> high", snmpCondition{}, true},
+		{">=", snmpCondition{}, true},
+		{"== ", snmpCondition{}, true},
+		{"90", snmpCondition{}, true},
+		{"=> 90", snmpCondition{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSNMPCondition(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseSNMPCondition(%q) = %+v, %v, want %+v (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSNMPConditionMatches(t *testing.T) {
+	tests := []struct {
+		condition string
+		value     string
+		want      bool
+	}{
+		{"> 90", "91", true},
+		{"> 90", "90", false},
+		{">= 90", "90", true},
+		{"< 10", "9.5", true},
+		{"<= 10", "10.1", false},
+		{"== 2", "2.0", true},
+		{"!= 2", "2", false},
+		{"!= 2", "3", true},
+		{"== up", "up", true},
+		{"== up", "down", false},
+		{"!= up", "down", true},
+		{"> 90", "n/a", false},
+		{"== 1", "one", false},
+		{"!= 1", "one", true},
+	}
+	for _, tt := range tests {
+		condition, err := parseSNMPCondition(tt.condition)
+		if err != nil {
+			t.Fatalf("parseSNMPCondition(%q): %v", tt.condition, err)
+		}
+		if got := condition.matches(tt.value); got != tt.want {
+			t.Errorf("%q matches %q = %v, want %v", tt.condition, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSNMPValue(t *testing.T) {
+	tests := []struct {
+		pdu  gosnmp.SnmpPDU
+		want string
+	}{
+		{gosnmp.SnmpPDU{Type: gosnmp.OctetString, Value: []byte("eth0")}, "eth0"},
+		{gosnmp.SnmpPDU{Type: gosnmp.Integer, Value: 42}, "42"},
+		{gosnmp.SnmpPDU{Type: gosnmp.Counter64, Value: uint64(18446744073709551615)}, "18446744073709551615"},
+		{gosnmp.SnmpPDU{Type: gosnmp.TimeTicks, Value: uint32(360000)}, "360000"},
+	}
+	for _, tt := range tests {
+		if got := snmpValue(tt.pdu); got != tt.want {
+			t.Errorf("snmpValue(%v) = %q, want %q", tt.pdu.Type, got, tt.want)
+		}
+	}
+}
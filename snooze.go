@@ -0,0 +1,82 @@
+package main
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// snoozeStore holds ad-hoc alert snoozes set through the API. A snooze
+// targets a service by name (covering all of its checks) or by service ID,
+// and expires on its own.
+type snoozeStore struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+}
+
+type snooze struct {
+	Service string    `json:"service"`
+	Until   time.Time `json:"until"`
+}
+
+func newSnoozeStore() *snoozeStore {
+	return &snoozeStore{expires: make(map[string]time.Time)}
+}
+
+func (s *snoozeStore) snooze(target string, d time.Duration) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until := time.Now().Add(d)
+	s.expires[target] = until
+	slog.Info("Alerts snoozed", "service", target, "until", until.Format(time.RFC3339))
+	return until
+}
+
+func (s *snoozeStore) unsnooze(target string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.expires[target]; !ok {
+		return false
+	}
+	delete(s.expires, target)
+	slog.Info("Alerts unsnoozed", "service", target)
+	return true
+}
+
+// expire drops snoozes that have run out, logging each one.
+func (s *snoozeStore) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for target, until := range s.expires {
+		if !now.Before(until) {
+			delete(s.expires, target)
+			slog.Info("Alert snooze expired", "service", target)
+		}
+	}
+}
+
+// snoozed reports whether alerts for service are currently suppressed.
+func (s *snoozeStore) snoozed(service Service) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, target := range []string{service.Name, service.ID()} {
+		if until, ok := s.expires[target]; ok && now.Before(until) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *snoozeStore) list() []snooze {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snoozes := make([]snooze, 0, len(s.expires))
+	for target, until := range s.expires {
+		snoozes = append(snoozes, snooze{Service: target, Until: until})
+	}
+	sort.Slice(snoozes, func(i, j int) bool { return snoozes[i].Service < snoozes[j].Service })
+	return snoozes
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+// dialer returns a dialer for the service's checks over network, bound to
+// the service's source_address when one is set so the check leaves through
+// that address's interface rather than the default route.
+func (s Service) dialer(network string, timeout time.Duration) *net.Dialer {
+	d := &net.Dialer{Timeout: timeout}
+	if ip := net.ParseIP(s.SourceAddress); ip != nil {
+		if strings.HasPrefix(network, "udp") {
+			d.LocalAddr = &net.UDPAddr{IP: ip}
+		} else {
+			d.LocalAddr = &net.TCPAddr{IP: ip}
+		}
+	}
+	return d
+}
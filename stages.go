@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// runStages runs services stage by stage in ascending order of Stage, each
+// stage in parallel through the pool. When a critical check in a stage is
+// DOWN, the services of all later stages are not checked and are reported as
+// SKIPPED, so an upstream outage (a router, say) does not also page for
+// everything behind it. With no stages configured this is just run.
+func (p *checkPool) runStages(ctx context.Context, services []Service, inFlight *inFlightChecks, jitter time.Duration) <-chan CheckResult {
+	byStage := make(map[int][]Service)
+	var stages []int
+	for _, service := range services {
+		if _, ok := byStage[service.Stage]; !ok {
+			stages = append(stages, service.Stage)
+		}
+		byStage[service.Stage] = append(byStage[service.Stage], service)
+	}
+	if len(stages) == 1 {
+		return p.run(ctx, services, inFlight, jitter)
+	}
+	sort.Ints(stages)
+
+	results := make(chan CheckResult)
+	go func() {
+		defer close(results)
+
+		var blocker *CheckResult
+		for _, stage := range stages {
+			if blocker != nil {
+				now := time.Now()
+				for _, service := range byStage[stage] {
+					skipped := CheckResult{Service: service, Time: now, Status: StatusSkipped, Error: fmt.Errorf("critical check %s (%s) in stage %d is down", blocker.Service.Name, blocker.Service.ID(), blocker.Service.Stage)}
+					select {
+					case results <- skipped:
+					case <-ctx.Done():
+						return
+					}
+				}
+				continue
+			}
+
+			for result := range p.run(ctx, byStage[stage], inFlight, jitter) {
+				if blocker == nil && result.Service.Critical && result.Status == StatusDown {
+					blocker = &result
+				}
+				select {
+				case results <- result:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return results
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// serviceState is the monitoring loop's alerting state for one service.
+type serviceState struct {
+	Status    Status    `json:"status"`
+	Failures  int       `json:"failures,omitempty"`   // Consecutive DOWN results
+	DownSince time.Time `json:"down_since,omitempty"` // Start of the current outage
+	Alerted   bool      `json:"alerted,omitempty"`    // A DOWN alert awaits its recovery notice
+	Degraded  bool      `json:"degraded,omitempty"`   // A degraded alert awaits its latency recovery notice
+}
+
+// stateStore holds the alerting state of every service. It is written by the
+// monitoring loop and safe for concurrent readers, and is persisted between
+// runs so a restart neither re-alerts for a known outage nor loses track of
+// what needs a recovery notice. It also keeps each service's recent results
+// for the rolling uptime.
+type stateStore struct {
+	mu       sync.RWMutex
+	services map[string]serviceState
+	recent   map[string]*resultRing
+	window   int // Results kept per service for the rolling uptime
+}
+
+// persistedState is the layout of the state file. Recent results are encoded as
+// by resultRing.String.
+type persistedState struct {
+	Services map[string]serviceState `json:"services"`
+	Recent   map[string]string       `json:"recent,omitempty"`
+}
+
+// loadState reads the state file, returning empty state if it doesn't exist.
+// window is how many recent results to keep per service, defaultUptimeWindow
+// if it is not positive.
+func loadState(path string, window int) (*stateStore, error) {
+	if window <= 0 {
+		window = defaultUptimeWindow
+	}
+	var file persistedState
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+		}
+	}
+	if file.Services == nil {
+		file.Services = make(map[string]serviceState)
+	}
+	recent := make(map[string]*resultRing, len(file.Recent))
+	for id, results := range file.Recent {
+		ring, err := parseResultRing(results, window)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse state file %s: %s: %w", path, id, err)
+		}
+		recent[id] = ring
+	}
+	return &stateStore{services: file.Services, recent: recent, window: window}, nil
+}
+
+func (s *stateStore) get(serviceID string) serviceState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.services[serviceID]
+}
+
+func (s *stateStore) set(serviceID string, state serviceState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.services[serviceID] = state
+}
+
+// record adds a result to the service's recent results. Results that say
+// nothing about the service are not recorded.
+func (s *stateStore) record(serviceID string, status Status) {
+	if !countsForUptime(status) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ring, ok := s.recent[serviceID]
+	if !ok {
+		ring = newResultRing(s.window)
+		s.recent[serviceID] = ring
+	}
+	ring.add(status == StatusUp || status == StatusWarn)
+}
+
+// uptime returns the fraction of the service's recent results that were UP or
+// WARN, and how many results that covers.
+func (s *stateStore) uptime(serviceID string) (float64, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ring, ok := s.recent[serviceID]
+	if !ok {
+		return 0, 0
+	}
+	return ring.uptime()
+}
+
+// retain drops the state of every service whose ID is not in ids, so services
+// removed by a reload don't linger in the state file.
+func (s *stateStore) retain(ids map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id := range s.services {
+		if !ids[id] {
+			delete(s.services, id)
+		}
+	}
+	for id := range s.recent {
+		if !ids[id] {
+			delete(s.recent, id)
+		}
+	}
+}
+
+// resize changes how many recent results are kept per service, as for
+// loadState, keeping the most recent ones if the window shrinks.
+func (s *stateStore) resize(window int) {
+	if window <= 0 {
+		window = defaultUptimeWindow
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if window == s.window {
+		return
+	}
+	s.window = window
+	for id, ring := range s.recent {
+		s.recent[id] = ring.resized(window)
+	}
+}
+
+// snapshot returns a copy of the state of every service.
+func (s *stateStore) snapshot() map[string]serviceState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshot := make(map[string]serviceState, len(s.services))
+	for id, state := range s.services {
+		snapshot[id] = state
+	}
+	return snapshot
+}
+
+// save writes the state to a temporary file and renames it into place, so a
+// crash mid-write leaves the previous state intact.
+func (s *stateStore) save(path string) error {
+	file := persistedState{Services: s.snapshot(), Recent: make(map[string]string)}
+	s.mu.RLock()
+	for id, ring := range s.recent {
+		file.Recent[id] = ring.String()
+	}
+	s.mu.RUnlock()
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
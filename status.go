@@ -0,0 +1,85 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// serviceStatus is the latest result of a service, as served by GET /status.
+type serviceStatus struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Host        string    `json:"host"`
+	Port        int       `json:"port"`
+	Check       string    `json:"check"`
+	Status      Status    `json:"status"`
+	LastChecked time.Time `json:"last_checked"`
+	LatencyMs   float64   `json:"latency_ms"`
+	Error       string    `json:"error,omitempty"`
+
+	// Rolling uptime over the service's recent results, filled in by the
+	// API from the state store.
+	UptimePercent *float64 `json:"uptime_percent,omitempty"`
+	UptimeChecks  int      `json:"uptime_checks,omitempty"`
+}
+
+// statusStore holds the latest result of every service. The monitoring loop
+// writes it after each cycle while API handlers read it concurrently.
+type statusStore struct {
+	mu        sync.RWMutex
+	latest    map[string]serviceStatus
+	lastCycle time.Time
+}
+
+func newStatusStore() *statusStore {
+	return &statusStore{latest: make(map[string]serviceStatus)}
+}
+
+func (s *statusStore) update(results []CheckResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, result := range results {
+		status := serviceStatus{
+			ID:          result.Service.ID(),
+			Name:        result.Service.Name,
+			Host:        result.Service.Host,
+			Port:        result.Service.Port,
+			Check:       result.Service.checkType(),
+			Status:      result.Status,
+			LastChecked: result.Time,
+			LatencyMs:   milliseconds(result.Latency),
+		}
+		if result.Error != nil {
+			status.Error = result.Error.Error()
+		}
+		s.latest[status.ID] = status
+	}
+	s.lastCycle = time.Now()
+}
+
+// retain drops the status of every service whose ID is not in ids.
+func (s *statusStore) retain(ids map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id := range s.latest {
+		if !ids[id] {
+			delete(s.latest, id)
+		}
+	}
+}
+
+// list returns the latest statuses sorted by service ID, and when the last
+// cycle finished.
+func (s *statusStore) list() ([]serviceStatus, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := make([]serviceStatus, 0, len(s.latest))
+	for _, status := range s.latest {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	return statuses, s.lastCycle
+}
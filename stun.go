@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
+)
+
+// STUNCheck sends a STUN Binding Request (RFC 5389) over UDP and validates the
+// Binding Success Response, optionally asserting the reflexive address the
+// server reports back.
+type STUNCheck struct {
+	Port            int    `yaml:"port"`             // Defaults to 3478
+	ExpectedAddress string `yaml:"expected_address"` // Optional reflexive IP to match
+}
+
+const (
+	stunDefaultPort       = 3478
+	stunMagicCookie       = 0x2112A442
+	stunBindingRequest    = 0x0001
+	stunBindingSuccess    = 0x0101
+	stunAttrMappedAddress = 0x0001
+	stunAttrXORMapped     = 0x0020
+)
+
+func (c *STUNCheck) port() int {
+	if c.Port == 0 {
+		return stunDefaultPort
+	}
+	return c.Port
+}
+
+// STUNChecker runs a server's stun check.
+type STUNChecker struct{ Service Service }
+
+func (c STUNChecker) Check(ctx context.Context) CheckResult {
+	service := c.Service
+	if _, err := checkSTUN(ctx, service.dialer("udp", service.Timeout), service.Host, service.STUN, service.Timeout); err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	return CheckResult{Service: service, Status: StatusUp}
+}
+
+// checkSTUN performs the binding exchange and returns the reflexive address.
+func checkSTUN(ctx context.Context, dialer *net.Dialer, host string, check *STUNCheck, timeout time.Duration) (net.IP, error) {
+	address := net.JoinHostPort(host, strconv.Itoa(check.port()))
+	conn, err := dialer.DialContext(ctx, "udp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	request := make([]byte, 20)
+	binary.BigEndian.PutUint16(request[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint32(request[4:8], stunMagicCookie)
+	txID := request[8:20]
+	if _, err := rand.Read(txID); err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write(request); err != nil {
+		return nil, fmt.Errorf("failed to send binding request: %w", err)
+	}
+
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, fmt.Errorf("no binding response: %w", err)
+	}
+
+	mapped, err := parseSTUNResponse(buf[:n], txID)
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("STUN binding succeeded", "server", address, "reflexive_address", mapped)
+
+	if check.ExpectedAddress != "" && !mapped.Equal(net.ParseIP(check.ExpectedAddress)) {
+		return mapped, fmt.Errorf("reflexive address %s does not match expected %s", mapped, check.ExpectedAddress)
+	}
+	return mapped, nil
+}
+
+// parseSTUNResponse validates a Binding Success Response for txID and
+// extracts the mapped address, preferring XOR-MAPPED-ADDRESS.
+func parseSTUNResponse(msg, txID []byte) (net.IP, error) {
+	if len(msg) < 20 {
+		return nil, errors.New("invalid STUN response: short message")
+	}
+	if binary.BigEndian.Uint32(msg[4:8]) != stunMagicCookie || !bytes.Equal(msg[8:20], txID) {
+		return nil, errors.New("invalid STUN response: transaction mismatch")
+	}
+	if msgType := binary.BigEndian.Uint16(msg[0:2]); msgType != stunBindingSuccess {
+		return nil, fmt.Errorf("unexpected STUN message type 0x%04x", msgType)
+	}
+
+	length := int(binary.BigEndian.Uint16(msg[2:4]))
+	if len(msg) < 20+length {
+		return nil, errors.New("invalid STUN response: truncated attributes")
+	}
+	attrs := msg[20 : 20+length]
+
+	var mapped net.IP
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:2])
+		attrLen := int(binary.BigEndian.Uint16(attrs[2:4]))
+		if len(attrs) < 4+attrLen {
+			break
+		}
+		value := attrs[4 : 4+attrLen]
+
+		switch attrType {
+		case stunAttrXORMapped:
+			if ip := parseSTUNAddress(value, msg[4:20]); ip != nil {
+				return ip, nil
+			}
+		case stunAttrMappedAddress:
+			mapped = parseSTUNAddress(value, nil)
+		}
+
+		// Attributes are padded to a multiple of 4 bytes, though a final
+		// one may arrive without its padding.
+		attrs = attrs[min(4+(attrLen+3)&^3, len(attrs)):]
+	}
+
+	if mapped == nil {
+		return nil, errors.New("STUN response has no mapped address")
+	}
+	return mapped, nil
+}
+
+// parseSTUNAddress decodes a (XOR-)MAPPED-ADDRESS value. For the XOR variant
+// xorKey is the magic cookie followed by the transaction ID.
+func parseSTUNAddress(value, xorKey []byte) net.IP {
+	if len(value) < 4 {
+		return nil
+	}
+	var size int
+	switch value[1] {
+	case 0x01:
+		size = net.IPv4len
+	case 0x02:
+		size = net.IPv6len
+	default:
+		return nil
+	}
+	if len(value) < 4+size {
+		return nil
+	}
+
+	ip := make(net.IP, size)
+	copy(ip, value[4:4+size])
+	for i := range ip {
+		if xorKey != nil {
+			ip[i] ^= xorKey[i]
+		}
+	}
+	return ip
+}
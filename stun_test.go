@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// stunResponse builds a Binding Success Response for txID holding attrs, which
+// are appended as they are, padding and all.
+func stunResponse(txID []byte, attrs ...[]byte) []byte {
+	msg := make([]byte, 20)
+	binary.BigEndian.PutUint16(msg[0:2], stunBindingSuccess)
+	binary.BigEndian.PutUint32(msg[4:8], stunMagicCookie)
+	copy(msg[8:20], txID)
+	for _, attr := range attrs {
+		msg = append(msg, attr...)
+	}
+	binary.BigEndian.PutUint16(msg[2:4], uint16(len(msg)-20))
+	return msg
+}
+
+// stunAttr builds an attribute header with the given length followed by value.
+func stunAttr(attrType uint16, length int, value []byte) []byte {
+	attr := make([]byte, 4, 4+len(value))
+	binary.BigEndian.PutUint16(attr[0:2], attrType)
+	binary.BigEndian.PutUint16(attr[2:4], uint16(length))
+	return append(attr, value...)
+}
+
+func TestParseSTUNResponseMalformedAttributes(t *testing.T) {
+	txID := []byte("0123456789ab")
+	mapped := stunAttr(stunAttrMappedAddress, 8, []byte{0, 0x01, 0x0d, 0x96, 192, 0, 2, 7})
+
+	tests := []struct {
+		name    string
+		msg     []byte
+		want    net.IP
+		wantErr bool
+	}{
+		{"unpadded final attribute", stunResponse(txID, mapped, stunAttr(0x8022, 5, []byte("relay"))), net.ParseIP("192.0.2.7"), false},
+		{"truncated final attribute", stunResponse(txID, mapped, stunAttr(0x8022, 64, []byte("relay"))), net.ParseIP("192.0.2.7"), false},
+		{"truncated mapped address", stunResponse(txID, stunAttr(stunAttrMappedAddress, 8, []byte{0, 0x01})), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip, err := parseSTUNResponse(tt.msg, txID)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseSTUNResponse = %v, want an error", ip)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSTUNResponse: %v", err)
+			}
+			if !ip.Equal(tt.want) {
+				t.Errorf("parseSTUNResponse = %v, want %v", ip, tt.want)
+			}
+		})
+	}
+}
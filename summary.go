@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// runSummary aggregates one check run, for tuning max_concurrency and the
+// check interval on large fleets.
+type runSummary struct {
+	Time      time.Time      `json:"time"`
+	Checks    int            `json:"checks"`
+	Statuses  map[Status]int `json:"statuses"` // Checks per status
+	WallTime  time.Duration  `json:"-"`
+	Slowest   *CheckResult   `json:"-"`
+	Throttled int            `json:"throttled"` // Checks that waited for a free worker slot
+
+	slowest []CheckResult // The slowest few checks, slowest first
+}
+
+// summarySlowest is how many of the slowest checks the summary lists.
+const summarySlowest = 3
+
+// summaryStatuses is the order statuses are listed in.
+var summaryStatuses = []Status{StatusUp, StatusWarn, StatusDown, StatusUnknown, StatusInactive, StatusSkipped}
+
+func summarize(results []CheckResult, start time.Time) runSummary {
+	s := runSummary{Time: start, Checks: len(results), Statuses: make(map[Status]int), WallTime: time.Since(start)}
+	for _, result := range results {
+		s.Statuses[result.Status]++
+		if result.Throttled {
+			s.Throttled++
+		}
+		if result.Status != StatusSkipped {
+			s.slowest = append(s.slowest, result)
+		}
+	}
+	sort.SliceStable(s.slowest, func(i, j int) bool { return s.slowest[i].Duration > s.slowest[j].Duration })
+	if len(s.slowest) > summarySlowest {
+		s.slowest = s.slowest[:summarySlowest]
+	}
+	if len(s.slowest) > 0 {
+		s.Slowest = &s.slowest[0]
+	}
+	return s
+}
+
+func (s runSummary) print() {
+	if quietOutput {
+		return
+	}
+	color.Cyan("Summary: %d checks in %s, %d throttled by max_concurrency", s.Checks, s.WallTime.Round(time.Millisecond), s.Throttled)
+
+	counts := []string{fmt.Sprintf("%d UP", s.Statuses[StatusUp])}
+	for _, status := range summaryStatuses[1:] {
+		if s.Statuses[status] > 0 || status == StatusDown {
+			counts = append(counts, fmt.Sprintf("%d %s", s.Statuses[status], status))
+		}
+	}
+	color.Cyan("Results: %s", strings.Join(counts, ", "))
+
+	if len(s.slowest) > 0 {
+		var slowest []string
+		for _, result := range s.slowest {
+			slowest = append(slowest, fmt.Sprintf("%s (%s) %s", result.Service.Name, result.Service.ID(), formatLatency(result.Duration)))
+		}
+		color.Cyan("Slowest: %s", strings.Join(slowest, ", "))
+	}
+}
+
+// summaryStore holds the latest run summary for the HTTP API.
+type summaryStore struct {
+	mu     sync.RWMutex
+	latest *runSummary
+}
+
+func (s *summaryStore) set(summary runSummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latest = &summary
+}
+
+func (s *summaryStore) get() *runSummary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.latest
+}
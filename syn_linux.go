@@ -0,0 +1,173 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	tcpFlagSYN = 0x02
+	tcpFlagRST = 0x04
+	tcpFlagACK = 0x10
+)
+
+// synPollInterval bounds each wait for a reply, so a cancelled probe stops
+// promptly rather than at its timeout.
+const synPollInterval = 100 * time.Millisecond
+
+// synProbe sends a single raw TCP SYN to host:port and waits for the reply
+// without completing the handshake. A SYN-ACK means the port is accepting
+// connections; the kernel answers it with a RST since no socket owns the
+// connection. Raw sockets require root or CAP_NET_RAW.
+func synProbe(ctx context.Context, host string, port int, timeout time.Duration) error {
+	dst, src, err := synRoute(ctx, host, port)
+	if err != nil {
+		return err
+	}
+
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_TCP)
+	if err != nil {
+		if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES) {
+			return fmt.Errorf("SYN check requires raw socket privileges (run as root or grant CAP_NET_RAW): %w", err)
+		}
+		return fmt.Errorf("failed to open raw socket: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	srcPort := 32768 + rand.IntN(28232)
+	seq := rand.Uint32()
+
+	sa := &syscall.SockaddrInet4{}
+	copy(sa.Addr[:], dst)
+	if err := syscall.Sendto(fd, buildSYN(src, dst, srcPort, port, seq), 0, sa); err != nil {
+		return fmt.Errorf("failed to send SYN: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	buf := make([]byte, 1500)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("%w from %s within %s", errSYNNoReply, net.JoinHostPort(host, strconv.Itoa(port)), timeout)
+		}
+		// Wake up periodically to notice cancellation.
+		remaining = min(remaining, synPollInterval)
+		tv := syscall.NsecToTimeval(remaining.Nanoseconds())
+		if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+			return fmt.Errorf("failed to set receive timeout: %w", err)
+		}
+
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) {
+				continue
+			}
+			return fmt.Errorf("failed to read SYN reply: %w", err)
+		}
+
+		flags, ok := matchSYNReply(buf[:n], dst, port, srcPort, seq)
+		if !ok {
+			continue
+		}
+		if flags&tcpFlagRST != 0 {
+			return fmt.Errorf("%w (RST from %s)", errSYNRefused, net.JoinHostPort(host, strconv.Itoa(port)))
+		}
+		if flags&(tcpFlagSYN|tcpFlagACK) == tcpFlagSYN|tcpFlagACK {
+			return nil
+		}
+	}
+}
+
+// synRoute resolves host to an IPv4 address and finds the local address the
+// kernel would route through to reach it. A host with only IPv6 addresses
+// can't be probed.
+func synRoute(ctx context.Context, host string, port int) (dst, src net.IP, err error) {
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, ip := range ips {
+		if dst = ip.To4(); dst != nil {
+			break
+		}
+	}
+	if dst == nil {
+		return nil, nil, fmt.Errorf("%w, %s has no IPv4 address", errSYNIPv6, host)
+	}
+
+	conn, err := net.Dial("udp4", net.JoinHostPort(dst.String(), strconv.Itoa(port)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("no route to %s: %w", dst, err)
+	}
+	defer conn.Close()
+	src = conn.LocalAddr().(*net.UDPAddr).IP.To4()
+
+	return dst, src, nil
+}
+
+// buildSYN assembles a bare TCP SYN segment; the kernel supplies the IP header.
+func buildSYN(src, dst net.IP, srcPort, dstPort int, seq uint32) []byte {
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp[0:2], uint16(srcPort))
+	binary.BigEndian.PutUint16(tcp[2:4], uint16(dstPort))
+	binary.BigEndian.PutUint32(tcp[4:8], seq)
+	tcp[12] = 5 << 4 // Data offset: 5 words, no options
+	tcp[13] = tcpFlagSYN
+	binary.BigEndian.PutUint16(tcp[14:16], 64240)
+
+	// Checksum over the IPv4 pseudo-header and the segment.
+	pseudo := make([]byte, 0, 12+len(tcp))
+	pseudo = append(pseudo, src...)
+	pseudo = append(pseudo, dst...)
+	pseudo = append(pseudo, 0, syscall.IPPROTO_TCP, 0, byte(len(tcp)))
+	pseudo = append(pseudo, tcp...)
+	binary.BigEndian.PutUint16(tcp[16:18], checksum(pseudo))
+
+	return tcp
+}
+
+// matchSYNReply reports the TCP flags of pkt if it is the reply to our SYN.
+func matchSYNReply(pkt []byte, dst net.IP, dstPort, srcPort int, seq uint32) (byte, bool) {
+	if len(pkt) < 20 || pkt[0]>>4 != 4 {
+		return 0, false
+	}
+	ihl := int(pkt[0]&0x0f) * 4
+	if len(pkt) < ihl+20 || !net.IP(pkt[12:16]).Equal(dst) {
+		return 0, false
+	}
+	tcp := pkt[ihl:]
+	if int(binary.BigEndian.Uint16(tcp[0:2])) != dstPort || int(binary.BigEndian.Uint16(tcp[2:4])) != srcPort {
+		return 0, false
+	}
+	flags := tcp[13]
+	if flags&tcpFlagACK != 0 && binary.BigEndian.Uint32(tcp[8:12]) != seq+1 {
+		return 0, false
+	}
+	return flags, true
+}
+
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
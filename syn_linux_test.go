@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want uint16
+	}{
+		// The example from RFC 1071, section 3.
+		{[]byte{0x00, 0x01, 0xf2, 0x03, 0xf4, 0xf5, 0xf6, 0xf7}, 0x220d},
+		// An odd length is padded with a zero byte.
+		{[]byte{0x00, 0x01, 0xf2}, ^uint16(0x0001 + 0xf200)},
+		{nil, 0xffff},
+	}
+	for _, tt := range tests {
+		if got := checksum(tt.data); got != tt.want {
+			t.Errorf("checksum(% x) = %#04x, want %#04x", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestBuildSYNChecksum(t *testing.T) {
+	src, dst := net.IPv4(192, 0, 2, 1).To4(), net.IPv4(198, 51, 100, 7).To4()
+	tcp := buildSYN(src, dst, 40000, 443, 12345)
+	// Summed with the checksum it carries, a valid segment checks to zero.
+	pseudo := append(append(append([]byte{}, src...), dst...), 0, syscall.IPPROTO_TCP, 0, byte(len(tcp)))
+	if got := checksum(append(pseudo, tcp...)); got != 0 {
+		t.Errorf("checksum over the SYN segment = %#04x, want 0", got)
+	}
+}
+
+// synReply builds an IPv4 packet from src carrying a TCP segment from srcPort
+// to dstPort with the given flags and acknowledgment number.
+func synReply(src net.IP, srcPort, dstPort int, flags byte, ack uint32) []byte {
+	pkt := make([]byte, 40)
+	pkt[0] = 0x45 // IPv4, 5-word header
+	copy(pkt[12:16], src.To4())
+	tcp := pkt[20:]
+	binary.BigEndian.PutUint16(tcp[0:2], uint16(srcPort))
+	binary.BigEndian.PutUint16(tcp[2:4], uint16(dstPort))
+	binary.BigEndian.PutUint32(tcp[8:12], ack)
+	tcp[13] = flags
+	return pkt
+}
+
+func TestMatchSYNReply(t *testing.T) {
+	dst := net.IPv4(198, 51, 100, 7)
+	const dstPort, srcPort, seq = 443, 40000, 1000
+
+	ipv6 := synReply(dst, dstPort, srcPort, tcpFlagSYN|tcpFlagACK, seq+1)
+	ipv6[0] = 0x60
+	tests := []struct {
+		name      string
+		pkt       []byte
+		wantFlags byte
+		wantOK    bool
+	}{
+		{"syn-ack", synReply(dst, dstPort, srcPort, tcpFlagSYN|tcpFlagACK, seq+1), tcpFlagSYN | tcpFlagACK, true},
+		{"rst-ack", synReply(dst, dstPort, srcPort, tcpFlagRST|tcpFlagACK, seq+1), tcpFlagRST | tcpFlagACK, true},
+		{"rst without ack", synReply(dst, dstPort, srcPort, tcpFlagRST, 0), tcpFlagRST, true},
+		{"wrong ack", synReply(dst, dstPort, srcPort, tcpFlagSYN|tcpFlagACK, seq+2), 0, false},
+		{"other host", synReply(net.IPv4(198, 51, 100, 8), dstPort, srcPort, tcpFlagSYN|tcpFlagACK, seq+1), 0, false},
+		{"other port", synReply(dst, dstPort+1, srcPort, tcpFlagSYN|tcpFlagACK, seq+1), 0, false},
+		{"other probe", synReply(dst, dstPort, srcPort+1, tcpFlagSYN|tcpFlagACK, seq+1), 0, false},
+		{"not ipv4", ipv6, 0, false},
+		{"truncated", synReply(dst, dstPort, srcPort, tcpFlagSYN|tcpFlagACK, seq+1)[:30], 0, false},
+	}
+	for _, tt := range tests {
+		flags, ok := matchSYNReply(tt.pkt, dst, dstPort, srcPort, seq)
+		if ok != tt.wantOK || flags != tt.wantFlags {
+			t.Errorf("%s: matchSYNReply = %#02x, %v, want %#02x, %v", tt.name, flags, ok, tt.wantFlags, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// synProbe is only implemented on Linux, where raw TCP sockets are available
+// to processes with CAP_NET_RAW.
+func synProbe(ctx context.Context, host string, port int, timeout time.Duration) error {
+	return errSYNUnsupported
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TCPProbeCheck sends a request over TCP and asserts on the size of the reply
+// and how long it took to arrive, for binary protocols where a connect proves
+// little.
+type TCPProbeCheck struct {
+	Send             string `yaml:"send"`               // Request bytes as text
+	SendHex          string `yaml:"send_hex"`           // Request bytes as hex, for binary protocols
+	ResponseBytes    int    `yaml:"response_bytes"`     // Exact reply size
+	MinResponseBytes int    `yaml:"min_response_bytes"` // Minimum reply size
+	MaxLatency       string `yaml:"max_latency"`        // Budget from send to the full reply
+
+	payload    []byte
+	maxLatency time.Duration
+}
+
+func (c *TCPProbeCheck) UnmarshalYAML(node *yaml.Node) error {
+	type plain TCPProbeCheck
+	if err := node.Decode((*plain)(c)); err != nil {
+		return err
+	}
+	switch {
+	case c.Send != "" && c.SendHex != "":
+		return fmt.Errorf("line %d: tcp_probe sets both send and send_hex", node.Line)
+	case c.SendHex != "":
+		payload, err := hex.DecodeString(c.SendHex)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid tcp_probe send_hex: %w", node.Line, err)
+		}
+		c.payload = payload
+	default:
+		c.payload = []byte(c.Send)
+	}
+	if c.ResponseBytes != 0 && c.MinResponseBytes != 0 {
+		return fmt.Errorf("line %d: tcp_probe sets both response_bytes and min_response_bytes", node.Line)
+	}
+	if c.MaxLatency != "" {
+		d, err := time.ParseDuration(c.MaxLatency)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid tcp_probe max_latency: %w", node.Line, err)
+		}
+		c.maxLatency = d
+	}
+	return nil
+}
+
+// maxTCPProbeResponseBytes caps response_bytes and min_response_bytes, which
+// size the read buffer.
+const maxTCPProbeResponseBytes = 1 << 20
+
+// expected is the number of reply bytes to wait for.
+func (c *TCPProbeCheck) expected() int {
+	switch {
+	case c.ResponseBytes > 0:
+		return c.ResponseBytes
+	case c.MinResponseBytes > 0:
+		return c.MinResponseBytes
+	default:
+		return 1
+	}
+}
+
+// TCPProbeChecker runs a port's tcp_probe.
+type TCPProbeChecker struct{ Service Service }
+
+func (c TCPProbeChecker) Check(ctx context.Context) CheckResult {
+	service := c.Service
+	latency, err := runTCPProbe(ctx, service.dialer("tcp", service.Timeout), service.Host, service.Port, service.TCPProbe, service.Timeout)
+	if err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	return CheckResult{Service: service, Status: StatusUp, Latency: latency}
+}
+
+// runTCPProbe returns how long the reply took from the end of the send, and
+// reports which condition of the probe failed, or nil if the reply met both
+// the size and latency requirements.
+func runTCPProbe(ctx context.Context, dialer *net.Dialer, host string, port int, probe *TCPProbeCheck, timeout time.Duration) (time.Duration, error) {
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	sent := time.Now()
+	if len(probe.payload) > 0 {
+		if _, err := conn.Write(probe.payload); err != nil {
+			return 0, fmt.Errorf("send failed: %w", err)
+		}
+	}
+
+	want := probe.expected()
+	buf := make([]byte, want+1)
+	n, err := io.ReadAtLeast(conn, buf[:want], want)
+	latency := time.Since(sent)
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return 0, fmt.Errorf("response size: got %d bytes, want %s", n, probe.sizeRequirement())
+		}
+		return 0, fmt.Errorf("response size: got %d bytes, want %s: %w", n, probe.sizeRequirement(), err)
+	}
+
+	// An exact size also rules out trailing bytes, which only arrive in a
+	// later segment if at all, so give them a brief moment.
+	if probe.ResponseBytes > 0 {
+		conn.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+		if extra, _ := conn.Read(buf[want:]); extra > 0 {
+			return 0, fmt.Errorf("response size: got more than %d bytes, want %s", want, probe.sizeRequirement())
+		}
+	}
+
+	if probe.maxLatency > 0 && latency > probe.maxLatency {
+		return 0, fmt.Errorf("latency: response took %s, budget %s", latency.Round(time.Millisecond), probe.maxLatency)
+	}
+	return latency, nil
+}
+
+func (c *TCPProbeCheck) sizeRequirement() string {
+	if c.ResponseBytes > 0 {
+		return fmt.Sprintf("exactly %d", c.ResponseBytes)
+	}
+	return fmt.Sprintf("at least %d", c.expected())
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TCPScriptCheck runs an ordered send/expect dialog over one TCP connection,
+// for line protocols such as SMTP or Redis where an open port proves little.
+type TCPScriptCheck struct {
+	Steps []ScriptStep `yaml:"steps"`
+}
+
+func (c *TCPScriptCheck) UnmarshalYAML(node *yaml.Node) error {
+	type plain TCPScriptCheck
+	if err := node.Decode((*plain)(c)); err != nil {
+		return err
+	}
+	if len(c.Steps) == 0 {
+		return fmt.Errorf("line %d: tcp_script has no steps", node.Line)
+	}
+	for i, step := range c.Steps {
+		if step.Send == "" && step.Expect == "" {
+			return fmt.Errorf("line %d: tcp_script step %d needs send or expect", node.Line, i+1)
+		}
+	}
+	return nil
+}
+
+// ScriptStep is one exchange in a scripted TCP dialog: optionally send some
+// bytes, then optionally wait for the server to reply with a substring.
+type ScriptStep struct {
+	Send   string `yaml:"send"`
+	Expect string `yaml:"expect"`
+}
+
+// TCPScriptChecker runs a port's tcp_script, or its send and expect.
+type TCPScriptChecker struct{ Service Service }
+
+func (c TCPScriptChecker) Check(ctx context.Context) CheckResult {
+	service := c.Service
+	latency, err := runTCPScript(ctx, service.dialer("tcp", service.Timeout), service.Host, service.Port, service.TCPScript.Steps, service.Timeout)
+	if err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	return CheckResult{Service: service, Status: StatusUp, Latency: latency}
+}
+
+// runTCPScript plays the steps over a single connection and returns how long
+// the dialog took once connected. The timeout bounds the whole dialog, not
+// each step, and the first failing step is reported.
+func runTCPScript(ctx context.Context, dialer *net.Dialer, host string, port int, steps []ScriptStep, timeout time.Duration) (time.Duration, error) {
+	deadline := time.Now().Add(timeout)
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+	conn.SetDeadline(deadline)
+	start := time.Now()
+
+	// Unconsumed reply bytes carry over, since a server may answer several
+	// steps in one segment.
+	var pending []byte
+	buf := make([]byte, 4096)
+	for i, step := range steps {
+		if step.Send != "" {
+			if _, err := conn.Write([]byte(step.Send)); err != nil {
+				return 0, fmt.Errorf("step %d: send failed: %w", i+1, err)
+			}
+		}
+		if step.Expect == "" {
+			continue
+		}
+
+		for {
+			if idx := bytes.Index(pending, []byte(step.Expect)); idx >= 0 {
+				pending = pending[idx+len(step.Expect):]
+				break
+			}
+			n, err := conn.Read(buf)
+			pending = append(pending, buf[:n]...)
+			if err != nil && !bytes.Contains(pending, []byte(step.Expect)) {
+				return 0, fmt.Errorf("step %d: expected %q, got %q: %w", i+1, step.Expect, truncate(string(pending), 64), err)
+			}
+		}
+	}
+	return time.Since(start), nil
+}
+
+// truncate shortens s to at most n bytes for error messages.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// defaultCertWarnDays is how close to expiry a certificate marks its port
+// DOWN when cert_warn_days is not set.
+const defaultCertWarnDays = 14
+
+// TLSChecker runs a port's tls check.
+type TLSChecker struct{ Service Service }
+
+// Check completes a TLS handshake with the service, fails it when the served
+// certificate is about to expire and, if requested, verifies the certificate
+// has not been revoked.
+func (c TLSChecker) Check(ctx context.Context) CheckResult {
+	service, timeout := c.Service, c.Service.Timeout
+	address := net.JoinHostPort(service.Host, strconv.Itoa(service.Port))
+	dialer := &tls.Dialer{NetDialer: service.dialer("tcp", timeout), Config: &tls.Config{ServerName: service.Host, InsecureSkipVerify: service.InsecureSkipVerify}}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	state := conn.(*tls.Conn).ConnectionState()
+	conn.Close()
+
+	expiry := state.PeerCertificates[0].NotAfter
+	warnDays := service.CertWarnDays
+	if warnDays == 0 {
+		warnDays = defaultCertWarnDays
+	}
+	if remaining := time.Until(expiry); remaining <= 0 {
+		return CheckResult{Service: service, Status: StatusDown, CertExpiry: expiry, Error: fmt.Errorf("certificate expired on %s", expiry.Format(time.RFC1123))}
+	} else if remaining < time.Duration(warnDays)*24*time.Hour {
+		return CheckResult{Service: service, Status: StatusDown, CertExpiry: expiry, Error: fmt.Errorf("certificate expires on %s, in %d days", expiry.Format(time.RFC1123), certDaysLeft(expiry))}
+	}
+
+	result := CheckResult{Service: service, Status: StatusUp}
+	if service.OCSP {
+		result = checkOCSP(ctx, service, state, timeout)
+	}
+	result.CertExpiry = expiry
+	return result
+}
+
+// certDaysLeft is the number of whole days until expiry.
+func certDaysLeft(expiry time.Time) int {
+	return int(time.Until(expiry) / (24 * time.Hour))
+}
+
+// checkOCSP reports DOWN when the served certificate is revoked, either per
+// the stapled OCSP response or by asking the certificate's OCSP responder.
+// An unreachable or inconclusive responder is a WARN, not an outage.
+func checkOCSP(ctx context.Context, service Service, state tls.ConnectionState, timeout time.Duration) CheckResult {
+	leaf, issuer := certAndIssuer(state)
+	if issuer == nil {
+		return CheckResult{Service: service, Status: StatusWarn, Error: errors.New("OCSP: issuer certificate not available")}
+	}
+
+	var resp *ocsp.Response
+	var err error
+	if len(state.OCSPResponse) > 0 {
+		resp, err = ocsp.ParseResponseForCert(state.OCSPResponse, leaf, issuer)
+	} else {
+		resp, err = queryOCSP(ctx, leaf, issuer, timeout)
+	}
+	if err != nil {
+		return CheckResult{Service: service, Status: StatusWarn, Error: fmt.Errorf("OCSP responder unavailable: %w", err)}
+	}
+
+	switch resp.Status {
+	case ocsp.Good:
+		return CheckResult{Service: service, Status: StatusUp}
+	case ocsp.Revoked:
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("certificate revoked at %s (reason: %s)", resp.RevokedAt.Format(time.RFC1123), ocspReason(resp.RevocationReason))}
+	default:
+		return CheckResult{Service: service, Status: StatusWarn, Error: errors.New("OCSP: responder does not know the certificate")}
+	}
+}
+
+// certAndIssuer picks the leaf and its issuer, preferring the verified chain.
+func certAndIssuer(state tls.ConnectionState) (leaf, issuer *x509.Certificate) {
+	if len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 1 {
+		return state.VerifiedChains[0][0], state.VerifiedChains[0][1]
+	}
+	if len(state.PeerCertificates) > 1 {
+		return state.PeerCertificates[0], state.PeerCertificates[1]
+	}
+	return state.PeerCertificates[0], nil
+}
+
+func queryOCSP(ctx context.Context, leaf, issuer *x509.Certificate, timeout time.Duration) (*ocsp.Response, error) {
+	if len(leaf.OCSPServer) == 0 {
+		return nil, errors.New("certificate has no OCSP responder")
+	}
+	req, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, leaf.OCSPServer[0], bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/ocsp-request")
+	client := &http.Client{Timeout: timeout}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("responder returned %s", httpResp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(httpResp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	return ocsp.ParseResponseForCert(body, leaf, issuer)
+}
+
+func ocspReason(reason int) string {
+	switch reason {
+	case ocsp.KeyCompromise:
+		return "key compromise"
+	case ocsp.CACompromise:
+		return "CA compromise"
+	case ocsp.AffiliationChanged:
+		return "affiliation changed"
+	case ocsp.Superseded:
+		return "superseded"
+	case ocsp.CessationOfOperation:
+		return "cessation of operation"
+	case ocsp.CertificateHold:
+		return "certificate hold"
+	case ocsp.RemoveFromCRL:
+		return "remove from CRL"
+	case ocsp.PrivilegeWithdrawn:
+		return "privilege withdrawn"
+	case ocsp.AACompromise:
+		return "AA compromise"
+	default:
+		return "unspecified"
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// UDPChecker verifies a UDP port. UDP is connectionless, so a dial alone
+// proves nothing: with a probe payload the port is UP only if the service
+// answers within the timeout. Without one, an empty datagram is sent and the
+// port is only reported DOWN if the host actively refuses it (ICMP port
+// unreachable); silence is indistinguishable from a filtered port and counts
+// as UP.
+type UDPChecker struct{ Service Service }
+
+func (c UDPChecker) Check(ctx context.Context) CheckResult {
+	service, timeout := c.Service, c.Service.Timeout
+	address := net.JoinHostPort(service.Host, strconv.Itoa(service.Port))
+	conn, err := service.dialer("udp", timeout).DialContext(ctx, "udp", address)
+	if err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+	defer conn.Close()
+	defer closeOnCancel(ctx, conn)()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	sent := time.Now()
+	if _, err := conn.Write([]byte(service.UDPProbe)); err != nil {
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("send failed: %w", err)}
+	}
+
+	buf := make([]byte, 1500)
+	_, err = conn.Read(buf)
+	latency := time.Since(sent)
+	switch {
+	case err == nil:
+		return CheckResult{Service: service, Status: StatusUp, Latency: latency}
+	case errors.Is(err, os.ErrDeadlineExceeded) && service.UDPProbe == "":
+		return CheckResult{Service: service, Status: StatusUp}
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return CheckResult{Service: service, Status: StatusDown, Error: fmt.Errorf("no response to probe within %s", timeout)}
+	default:
+		return CheckResult{Service: service, Status: StatusDown, Error: err}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultUptimeWindow is how many recent results per service the rolling
+// uptime covers.
+const defaultUptimeWindow = 100
+
+// resultRing is a fixed-size ring buffer of a service's most recent results,
+// each recorded as up or not, from which the rolling uptime is computed.
+type resultRing struct {
+	up    []bool
+	next  int // Slot the next result is written to
+	count int // Slots filled, up to len(up)
+}
+
+func newResultRing(size int) *resultRing {
+	return &resultRing{up: make([]bool, size)}
+}
+
+// countsForUptime reports whether a result says anything about the service.
+// INACTIVE, UNKNOWN and SKIPPED results don't and are left out, as they are
+// from the -db uptime.
+func countsForUptime(status Status) bool {
+	return status != StatusInactive && status != StatusUnknown && status != StatusSkipped
+}
+
+func (r *resultRing) add(up bool) {
+	r.up[r.next] = up
+	r.next = (r.next + 1) % len(r.up)
+	r.count = min(r.count+1, len(r.up))
+}
+
+// resized returns a ring of the given size holding the most recent of r's
+// results that fit.
+func (r *resultRing) resized(size int) *resultRing {
+	resized := newResultRing(size)
+	start := (r.next - r.count + len(r.up)) % len(r.up)
+	for i := max(0, r.count-size); i < r.count; i++ {
+		resized.add(r.up[(start+i)%len(r.up)])
+	}
+	return resized
+}
+
+// uptime returns the fraction of the recorded results that were up, and how
+// many results that covers.
+func (r *resultRing) uptime() (float64, int) {
+	if r.count == 0 {
+		return 0, 0
+	}
+	up := 0
+	for i := range r.count {
+		if r.up[i] {
+			up++
+		}
+	}
+	return float64(up) / float64(r.count), r.count
+}
+
+// String encodes the recorded results oldest first, U for up and D for down,
+// as they are kept in the state file.
+func (r *resultRing) String() string {
+	var b strings.Builder
+	start := (r.next - r.count + len(r.up)) % len(r.up)
+	for i := range r.count {
+		if r.up[(start+i)%len(r.up)] {
+			b.WriteByte('U')
+		} else {
+			b.WriteByte('D')
+		}
+	}
+	return b.String()
+}
+
+// parseResultRing rebuilds a ring of the given size from its String form,
+// keeping the most recent results if there are more than fit.
+func parseResultRing(s string, size int) (*resultRing, error) {
+	r := newResultRing(size)
+	for _, c := range s[max(0, len(s)-size):] {
+		switch c {
+		case 'U':
+			r.add(true)
+		case 'D':
+			r.add(false)
+		default:
+			return nil, fmt.Errorf("unexpected %q in recent results", c)
+		}
+	}
+	return r, nil
+}
+
+// formatUptime describes a rolling uptime for alerts, e.g. "97.0% over the
+// last 100 checks".
+func formatUptime(fraction float64, checks int) string {
+	noun := "checks"
+	if checks == 1 {
+		noun = "check"
+	}
+	return fmt.Sprintf("%.1f%% over the last %d %s", fraction*100, checks, noun)
+}
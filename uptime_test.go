@@ -0,0 +1,96 @@
+package main
+
+import "testing"
+
+func TestResultRing(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int
+		results    string
+		wantString string
+		wantUptime float64
+		wantChecks int
+	}{
+		{"empty", 4, "", "", 0, 0},
+		{"partly filled", 4, "UUD", "UUD", 2.0 / 3, 3},
+		{"full", 4, "UDUU", "UDUU", 0.75, 4},
+		{"wrapped", 4, "DDUUUD", "UUUD", 0.75, 4},
+		{"wrapped twice", 2, "DUDUDU", "DU", 0.5, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newResultRing(tt.size)
+			for _, c := range tt.results {
+				r.add(c == 'U')
+			}
+			if got := r.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+			uptime, checks := r.uptime()
+			if uptime != tt.wantUptime || checks != tt.wantChecks {
+				t.Errorf("uptime() = %v, %d, want %v, %d", uptime, checks, tt.wantUptime, tt.wantChecks)
+			}
+			parsed, err := parseResultRing(r.String(), tt.size)
+			if err != nil {
+				t.Fatalf("parseResultRing: %v", err)
+			}
+			if got := parsed.String(); got != tt.wantString {
+				t.Errorf("parsed String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestParseResultRingKeepsMostRecent(t *testing.T) {
+	r, err := parseResultRing("DDDUU", 3)
+	if err != nil {
+		t.Fatalf("parseResultRing: %v", err)
+	}
+	if got := r.String(); got != "DUU" {
+		t.Errorf("String() = %q, want DUU", got)
+	}
+	if _, err := parseResultRing("UXU", 3); err == nil {
+		t.Error("parseResultRing accepted an invalid result")
+	}
+}
+
+func TestResultRingResized(t *testing.T) {
+	r := newResultRing(4)
+	for _, c := range "DDUUUD" {
+		r.add(c == 'U')
+	}
+	tests := []struct {
+		size int
+		want string
+	}{
+		{2, "UD"},
+		{4, "UUUD"},
+		{6, "UUUD"},
+	}
+	for _, tt := range tests {
+		resized := r.resized(tt.size)
+		if got := resized.String(); got != tt.want {
+			t.Errorf("resized(%d).String() = %q, want %q", tt.size, got, tt.want)
+		}
+		resized.add(true)
+		if _, checks := resized.uptime(); checks != min(len(tt.want)+1, tt.size) {
+			t.Errorf("resized(%d) holds %d results after one more, want %d", tt.size, checks, min(len(tt.want)+1, tt.size))
+		}
+	}
+}
+
+func TestStateStoreResizeAndRetain(t *testing.T) {
+	s := &stateStore{services: make(map[string]serviceState), recent: make(map[string]*resultRing), window: 4}
+	for _, status := range []Status{StatusDown, StatusUp, StatusUp, StatusUp} {
+		s.record("db:5432", status)
+		s.record("old:22", status)
+	}
+	s.resize(2)
+	if uptime, checks := s.uptime("db:5432"); uptime != 1 || checks != 2 {
+		t.Errorf("uptime after shrinking = %v over %d, want 1 over 2", uptime, checks)
+	}
+	s.retain(map[string]bool{"db:5432": true})
+	if _, checks := s.uptime("old:22"); checks != 0 {
+		t.Errorf("removed service still has %d recent results", checks)
+	}
+}
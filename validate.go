@@ -0,0 +1,311 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// configProblems is every mistake validateConfig found in a configuration.
+type configProblems []string
+
+func (p configProblems) Error() string {
+	return "invalid configuration: " + strings.Join(p, "; ")
+}
+
+// validateConfig checks the loaded configuration for mistakes that would
+// otherwise be silently ignored or fail at check time, reporting every
+// problem at once.
+func validateConfig(cfg *Config) error {
+	var problems []string
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	duration := func(field, value string) {
+		if value == "" {
+			return
+		}
+		if _, err := time.ParseDuration(value); err != nil {
+			add("%s: %q is not a valid duration", field, value)
+		}
+	}
+
+	interval := func(field, value string) {
+		if value == "" {
+			return
+		}
+		if _, err := parseCheckInterval(value); err != nil {
+			add("%s: %v", field, err)
+		}
+	}
+
+	interval("check_interval", cfg.CheckInterval)
+	duration("timeout", cfg.Timeout)
+	duration("check_duration_threshold", cfg.CheckDurationThreshold)
+	duration("latency_threshold", cfg.LatencyThreshold)
+	duration("shutdown_timeout", cfg.ShutdownTimeout)
+	duration("mass_failure_recheck_delay", cfg.MassFailureRecheckDelay)
+	duration("cycle_timeout", cfg.CycleTimeout)
+	duration("alert_cooldown", cfg.AlertCooldown)
+	duration("startup_delay", cfg.StartupDelay)
+
+	duration("ping_interval", cfg.PingInterval)
+	if cfg.PingCount < 0 {
+		add("ping_count must not be negative, got %d", cfg.PingCount)
+	}
+	if cfg.Retries < 0 {
+		add("retries must not be negative, got %d", cfg.Retries)
+	}
+	if cfg.UptimeWindow < 0 {
+		add("uptime_window must not be negative, got %d", cfg.UptimeWindow)
+	}
+	if cfg.Jitter < 0 || cfg.Jitter > maxJitter {
+		add("jitter must be between 0 and %d percent, got %d", maxJitter, cfg.Jitter)
+	}
+	if cfg.SourceAddress != "" && net.ParseIP(cfg.SourceAddress) == nil {
+		add("source_address %q is not an IP address", cfg.SourceAddress)
+	}
+
+	rangePorts := 0
+	for i, server := range cfg.Servers {
+		where := fmt.Sprintf("servers[%d]", i)
+		if server.Name == "" {
+			add("%s: name is required", where)
+		} else {
+			where = fmt.Sprintf("servers[%d] (%s)", i, server.Name)
+		}
+		if server.Host == "" {
+			add("%s: host is required", where)
+		}
+		for _, port := range server.Ports {
+			if port.Port < 1 || port.Port > 65535 || (port.EndPort != 0 && port.EndPort > 65535) {
+				add("%s: port %s is out of range 1-65535", where, port.label())
+			} else if port.EndPort != 0 {
+				if port.EndPort < port.Port {
+					add("%s: port range %s must be ascending", where, port.label())
+				} else {
+					rangePorts += port.EndPort - port.Port + 1
+				}
+			}
+			if port.Protocol != "" && port.Protocol != "tcp" && port.Protocol != "udp" {
+				add("%s: port %s has unknown protocol %q, want tcp or udp", where, port.label(), port.Protocol)
+			}
+			if (port.Probe != "" || port.ProbeHex != "") && port.Protocol != "udp" {
+				add("%s: port %s sets a probe without protocol udp", where, port.label())
+			}
+			if port.OCSP && !port.TLS {
+				add("%s: port %s sets ocsp without tls", where, port.label())
+			}
+			if port.Send != "" && port.Expect == "" {
+				add("%s: port %s sets send without expect", where, port.label())
+			}
+			if port.Expect != "" && (port.Protocol == "udp" || port.TCPScript != nil) {
+				add("%s: port %s sets expect, which can't be combined with protocol udp or tcp_script", where, port.label())
+			}
+			if port.GRPCService != "" && !port.GRPC {
+				add("%s: port %s sets grpc_service without grpc", where, port.label())
+			}
+			if port.GRPC && port.GRPCReflection != nil {
+				add("%s: port %s sets both grpc and grpc_reflection", where, port.label())
+			}
+			if server.SYN && (port.Protocol == "udp" || port.TLS || port.GRPC || port.GRPCReflection != nil || port.TCPScript != nil || port.TCPProbe != nil || port.Expect != "") {
+				add("%s: port %s can't be combined with syn, which only probes plain TCP ports", where, port.label())
+			}
+			if probe := port.TCPProbe; probe != nil {
+				size := func(field string, value int) {
+					if value < 0 || value > maxTCPProbeResponseBytes {
+						add("%s: port %s tcp_probe %s must be between 0 and %d, got %d", where, port.label(), field, maxTCPProbeResponseBytes, value)
+					}
+				}
+				size("response_bytes", probe.ResponseBytes)
+				size("min_response_bytes", probe.MinResponseBytes)
+				if probe.maxLatency < 0 {
+					add("%s: port %s tcp_probe max_latency must not be negative", where, port.label())
+				}
+			}
+			switch port.State {
+			case "", "open":
+			case "closed":
+				if port.Protocol == "udp" || port.TLS || port.GRPC || port.GRPCReflection != nil || port.TCPScript != nil || port.TCPProbe != nil || port.Expect != "" {
+					add("%s: port %s sets state closed, which only applies to a plain TCP or SYN check", where, port.label())
+				}
+			default:
+				add("%s: port %s has unknown state %q, want open or closed", where, port.label(), port.State)
+			}
+		}
+		for _, check := range server.HTTPChecks {
+			if u, err := url.Parse(check.URL); err != nil || u.Scheme == "" || u.Host == "" {
+				add("%s: http check URL %q is not an absolute URL", where, check.URL)
+			}
+			if check.BasicAuth != nil && check.BasicAuth.Username == "" {
+				add("%s: http check %s: basic_auth.username is required", where, check.URL)
+			}
+			if check.BasicAuth != nil && check.BearerToken != "" {
+				add("%s: http check %s sets both basic_auth and bearer_token", where, check.URL)
+			}
+		}
+		if server.DNS != nil {
+			switch server.DNS.recordType() {
+			case "A", "AAAA":
+				if server.DNS.Expect != "" && net.ParseIP(server.DNS.Expect) == nil {
+					add("%s: dns.expect %q is not an IP address", where, server.DNS.Expect)
+				}
+			case "CNAME", "MX":
+			default:
+				add("%s: dns.type %q must be A, AAAA, CNAME or MX", where, server.DNS.Type)
+			}
+		}
+		if server.STUN != nil && server.STUN.ExpectedAddress != "" && net.ParseIP(server.STUN.ExpectedAddress) == nil {
+			add("%s: stun.expected_address %q is not an IP address", where, server.STUN.ExpectedAddress)
+		}
+		for _, problem := range validateLabels(server.Labels) {
+			add("%s: labels: %s", where, problem)
+		}
+		if server.SNMP != nil {
+			for _, problem := range server.SNMP.validate() {
+				add("%s: snmp: %s", where, problem)
+			}
+		}
+		if server.MX != nil && (server.MX.Port < 0 || server.MX.Port > 65535) {
+			add("%s: mx.port %d is out of range 1-65535", where, server.MX.Port)
+		}
+		if server.Exec != nil && server.Exec.Command == "" {
+			add("%s: exec.command is required", where)
+		}
+		for j, window := range server.MaintenanceWindows {
+			if err := window.validate(); err != nil {
+				add("%s: maintenance_windows[%d]: %v", where, j, err)
+			}
+		}
+		if _, ok := cfg.Groups[server.Group]; server.Group != "" && !ok {
+			add("%s: group %q is not defined under groups in config.yaml", where, server.Group)
+		}
+		interval(where+": interval", server.Interval)
+		duration(where+": timeout", server.Timeout)
+		duration(where+": latency_threshold", server.LatencyThreshold)
+		if server.SourceAddress != "" && net.ParseIP(server.SourceAddress) == nil {
+			add("%s: source_address %q is not an IP address", where, server.SourceAddress)
+		}
+		duration(where+": ping_interval", server.PingInterval)
+		if server.PingCount < 0 {
+			add("%s: ping_count must not be negative, got %d", where, server.PingCount)
+		}
+		if server.pingOnly() {
+			// Sending every packet must fit in the check interval, or the
+			// cycle deadline abandons the ping before it is done.
+			count, pingInterval, _, err := pingSettings(cfg, server)
+			checkInterval, intervalErr := parseCheckInterval(cmp.Or(server.Interval, cfg.CheckInterval, "60s"))
+			if sending := time.Duration(max(count-1, 0)) * pingInterval; err == nil && intervalErr == nil && sending >= checkInterval {
+				add("%s: sending %d pings %s apart takes %s, not less than the check interval of %s; lower ping_count or ping_interval", where, count, pingInterval, sending, checkInterval)
+			}
+		}
+	}
+	for i, window := range cfg.MaintenanceWindows {
+		if err := window.validate(); err != nil {
+			add("maintenance_windows[%d]: %v", i, err)
+		}
+	}
+	if rangePorts > maxRangePorts {
+		add("port ranges expand to %d ports, more than the limit of %d", rangePorts, maxRangePorts)
+	}
+
+	// SMTP needs a host and port; credentials are optional, for relays that
+	// accept mail without authentication, but only work as a pair.
+	smtpFields := map[string]bool{
+		"smtp.host":     cfg.SMTP.Host != "",
+		"smtp.port":     cfg.SMTP.Port != 0,
+		"smtp.username": cfg.SMTP.Username != "",
+		"smtp.password": cfg.SMTP.Password != "",
+	}
+	for _, pair := range [][2]string{{"smtp.host", "smtp.port"}, {"smtp.username", "smtp.password"}} {
+		if smtpFields[pair[0]] != smtpFields[pair[1]] {
+			set, missing := pair[0], pair[1]
+			if !smtpFields[set] {
+				set, missing = missing, set
+			}
+			add("smtp: %s set but %s missing", set, missing)
+		}
+	}
+	if cfg.SMTP.Host == "" && (smtpFields["smtp.username"] || smtpFields["smtp.password"]) {
+		add("smtp: credentials set but smtp.host missing")
+	}
+	if cfg.SMTP.Port < 0 || cfg.SMTP.Port > 65535 {
+		add("smtp.port %d is out of range 1-65535", cfg.SMTP.Port)
+	}
+	if cfg.SMTP.Host != "" && cfg.AlertRecipient == "" {
+		add("alert_recipient is required when smtp is configured")
+	}
+
+	if len(cfg.Kafka.Brokers) > 0 && cfg.Kafka.Topic == "" {
+		add("kafka.brokers is set but kafka.topic is empty")
+	}
+
+	channels := func(prefix string, telegram *TelegramConfig, pagerDuty *PagerDutyConfig, webhook *WebhookConfig) {
+		if telegram != nil {
+			// A group's chat may be served by the global bot.
+			hasToken := telegram.BotToken != "" || (prefix != "" && cfg.Telegram.BotToken != "")
+			if telegram.ChatID == "" && hasToken {
+				add("%stelegram.chat_id is required", prefix)
+			} else if telegram.ChatID != "" && !hasToken {
+				add("%stelegram.bot_token is required", prefix)
+			}
+		}
+		if pagerDuty != nil {
+			switch pagerDuty.Severity {
+			case "", "critical", "error", "warning", "info":
+			default:
+				add("%spagerduty.severity %q must be critical, error, warning or info", prefix, pagerDuty.Severity)
+			}
+		}
+		if webhook != nil && webhook.URL != "" {
+			if u, err := url.Parse(webhook.URL); err != nil || u.Scheme == "" || u.Host == "" {
+				add("%swebhook.url is not an absolute URL", prefix)
+			}
+			if _, err := parseWebhookTemplate(webhook.Template); err != nil {
+				add("%swebhook.template: %v", prefix, err)
+			}
+		}
+	}
+	if _, err := parseEmailTemplate("email_subject_template", cfg.EmailSubjectTemplate); err != nil {
+		add("email_subject_template: %v", err)
+	}
+	if _, err := parseEmailTemplate("email_body_template", cfg.EmailBodyTemplate); err != nil {
+		add("email_body_template: %v", err)
+	}
+	channels("", &cfg.Telegram, &cfg.PagerDuty, &cfg.Webhook)
+	for _, name := range slices.Sorted(maps.Keys(cfg.Groups)) {
+		group := cfg.Groups[name]
+		channels("groups."+name+".", group.Telegram, group.PagerDuty, group.Webhook)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return configProblems(problems)
+}
+
+// checkConfig loads the configuration the way a run would, without running
+// any checks, and prints "config OK" or every problem found. It returns the
+// exit status for -validate: 0 when the configuration is valid, 1 otherwise.
+func checkConfig(serverFile string) int {
+	if _, _, _, err := loadServices(serverFile, false); err != nil {
+		var problems configProblems
+		if !errors.As(err, &problems) {
+			problems = configProblems{err.Error()}
+		}
+		fmt.Fprintf(os.Stderr, "config has %d problem(s):\n", len(problems))
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, "  -", problem)
+		}
+		return 1
+	}
+	fmt.Println("config OK")
+	return 0
+}
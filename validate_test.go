@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []string // Substrings of the expected problems, in order
+	}{
+		{"valid", `
+servers:
+  - name: web
+    host: 192.0.2.1
+    ports: [80, 443]
+`, nil},
+		{"missing name and host", `
+servers:
+  - ports: [80]
+`, []string{"name is required", "host is required"}},
+		{"port out of range", `
+servers:
+  - name: web
+    host: 192.0.2.1
+    ports: [70000]
+`, []string{"port 70000 is out of range"}},
+		{"syn with tls", `
+servers:
+  - name: web
+    host: 192.0.2.1
+    syn: true
+    ports:
+      - 80
+      - port: 443
+        tls: true
+`, []string{"port 443 can't be combined with syn"}},
+		{"syn with udp and grpc", `
+servers:
+  - name: app
+    host: 192.0.2.1
+    syn: true
+    ports:
+      - port: 53
+        protocol: udp
+      - port: 50051
+        grpc: true
+`, []string{"port 53/udp can't be combined with syn", "port 50051 can't be combined with syn"}},
+		{"stun expected address", `
+servers:
+  - name: stun
+    host: 192.0.2.1
+    stun:
+      expected_address: 203.0.113.300
+`, []string{`stun.expected_address "203.0.113.300" is not an IP address`}},
+		{"pings outlast the interval", `
+check_interval: 10s
+servers:
+  - name: lo
+    host: 127.0.0.1
+    ping_count: 12
+`, []string{"sending 12 pings 1s apart takes 11s"}},
+		{"bad durations", `
+timeout: 5
+servers:
+  - name: web
+    host: 192.0.2.1
+    ports: [80]
+    interval: 500ms
+`, []string{`timeout: "5" is not a valid duration`, "interval: 500ms is shorter than the minimum"}},
+		{"negative settings", `
+retries: -1
+uptime_window: -5
+servers:
+  - name: web
+    host: 192.0.2.1
+    ports: [80]
+`, []string{"retries must not be negative", "uptime_window must not be negative"}},
+		{"tcp_probe sizes", `
+servers:
+  - name: game
+    host: 192.0.2.1
+    ports:
+      - port: 27015
+        tcp_probe: {send: ping, response_bytes: -1}
+      - port: 27016
+        tcp_probe: {send: ping, min_response_bytes: 2000000000}
+      - port: 27017
+        tcp_probe: {send: ping, response_bytes: 1048576}
+`, []string{"port 27015 tcp_probe response_bytes must be between 0 and 1048576, got -1", "port 27016 tcp_probe min_response_bytes must be between 0 and 1048576"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			if err := unmarshalConfig("servers.yaml", []byte(tt.yaml), &cfg); err != nil {
+				t.Fatalf("unmarshalConfig: %v", err)
+			}
+			var problems configProblems
+			if err := validateConfig(&cfg); err != nil && !errors.As(err, &problems) {
+				t.Fatalf("validateConfig returned %v, want configProblems", err)
+			}
+			if len(problems) != len(tt.want) {
+				t.Fatalf("validateConfig found %q, want %d problem(s)", problems, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(problems[i], want) {
+					t.Errorf("problem %d = %q, want it to contain %q", i, problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestUnmarshalConfigUnknownKeys(t *testing.T) {
+	data := []byte(`version: 1
+timout: 5s
+servers:
+  - name: web
+    host: 192.0.2.1
+    labels: {env: prod}
+    ports:
+      - port: 443
+        tsl: true
+    http_checks:
+      - url: https://example.com
+        basic_auth: {username: u, pasword: p}
+`)
+	var cfg Config
+	err := unmarshalConfig("servers.yaml", data, &cfg)
+	if err == nil {
+		t.Fatal("unmarshalConfig succeeded with misspelled keys")
+	}
+	for _, want := range []string{
+		`line 2: unknown key "timout"`,
+		`line 9: unknown key "tsl" in servers[0].ports[0]`,
+		`line 12: unknown key "pasword" in servers[0].http_checks[0].basic_auth`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("unmarshalConfig error %q does not contain %q", err, want)
+		}
+	}
+}
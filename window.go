@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TimeWindow is a daily time-of-day range in local time, written as
+// "01:00-03:00". A window whose end is before its start wraps past midnight.
+type TimeWindow struct {
+	start, end int // Minutes since midnight
+	text       string
+}
+
+func (w *TimeWindow) UnmarshalYAML(node *yaml.Node) error {
+	if err := node.Decode(&w.text); err != nil {
+		return err
+	}
+	from, to, ok := strings.Cut(w.text, "-")
+	if !ok {
+		return fmt.Errorf("line %d: invalid time window %q, want HH:MM-HH:MM", node.Line, w.text)
+	}
+	var err error
+	if w.start, err = parseClock(from); err != nil {
+		return fmt.Errorf("line %d: invalid time window %q: %w", node.Line, w.text, err)
+	}
+	if w.end, err = parseClock(to); err != nil {
+		return fmt.Errorf("line %d: invalid time window %q: %w", node.Line, w.text, err)
+	}
+	if w.start == w.end {
+		return fmt.Errorf("line %d: time window %q is empty", node.Line, w.text)
+	}
+	return nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a HH:MM time", strings.TrimSpace(s))
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether t falls inside the window. The end is exclusive.
+func (w *TimeWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
+
+func (w *TimeWindow) String() string {
+	return w.text
+}